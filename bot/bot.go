@@ -2,53 +2,92 @@ package bot
 
 import (
 	"codstatusbot2.0/command"
+	"codstatusbot2.0/config"
+	"codstatusbot2.0/discordapi"
 	"codstatusbot2.0/services"
-	"errors"
-	"os"
+	"strings"
 
 	"codstatusbot2.0/logger"
 	"github.com/bwmarrin/discordgo"
 )
 
-var discord *discordgo.Session
+var shards *ShardManager
 
-func StartBot() error {
-	envToken := os.Getenv("DISCORD_TOKEN")
-	if envToken == "" {
-		err := errors.New("DISCORD_TOKEN environment variable not set")
-		logger.Log.WithError(err).WithField("env", "DISCORD_TOKEN").Error()
+const (
+	watchStatus  = "the Status of your Accounts so you dont have to."
+	outageStatus = "for Activision to come back, checks paused"
+)
+
+func StartBot(cfg *config.Config) error {
+	services.Configure(cfg)
+	if err := services.EncryptStoredCookies(); err != nil {
+		logger.Log.WithError(err).WithField("Bot startup", "Encrypting SSO cookies").Error()
 		return err
 	}
+	services.StartHTTP()
 	var err error
-	discord, err = discordgo.New("Bot " + envToken)
+	shards, err = NewShardManager(cfg.Discord.Token, cfg.Discord.ShardCount)
 	if err != nil {
 		logger.Log.WithError(err).WithField("Bot startup", "Token").Error()
 		return err
 	}
 
-	err = discord.Open()
+	err = shards.Open()
 	if err != nil {
 		logger.Log.WithError(err).WithField("Bot startup", "Opening Session").Error()
 		return err
 	}
 
-	err = discord.UpdateWatchStatus(0, "the Status of your Accounts so you dont have to.")
+	err = shards.UpdateWatchStatus(watchStatus)
 	if err != nil {
 		logger.Log.WithError(err).WithField("Bot startup", "Setting Presence Status").Error()
 		return err
 	}
 
-	guilds, err := discord.UserGuilds(100, "", "", false)
+	guilds, err := shards.Guilds()
 	if err != nil {
 		logger.Log.WithError(err).WithField("Bot startup", "Initiating Guilds").Error()
 		return err
 	}
 	for _, guild := range guilds {
 		logger.Log.WithField("guild", guild.Name).Info("Connected to guild")
-		command.RegisterCommands(discord, guild.ID)
+		command.RegisterCommands(shards.SessionForGuild(guild.ID), guild.ID)
 	}
 
-	discord.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	shards.AddHandler(OnInteractionCreate)
+	shards.AddHandler(OnGuildCreate)
+	shards.AddHandler(OnGuildDelete)
+	services.OnActivisionStatusChange(func(open bool) {
+		status := watchStatus
+		if open {
+			status = outageStatus
+		}
+		if err := shards.UpdateWatchStatus(status); err != nil {
+			logger.Log.WithError(err).Error("Error updating presence status")
+		}
+	})
+	go services.CheckAccounts(shards)
+	go services.RunSingleton("digest", cfg.Intervals.Sleep, func() { services.SendDigests(shards) })
+	go services.RunSingleton(services.AutoClaimJob, cfg.Intervals.Sleep, func() { services.AutoClaimRewards(shards) })
+	go services.RunSingleton("banwave", cfg.Intervals.Sleep, func() { services.ResolveBanWaves(shards) })
+	return nil
+}
+
+func OnInteractionCreate(session *discordgo.Session, i *discordgo.InteractionCreate) {
+	s := discordapi.Wrap(session)
+	if !command.Allowed(i) {
+		logger.Log.WithField("user_id", i.Member.User.ID).Info("Member is not allowed to use the bot in this guild")
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "You don't have a role that is allowed to use this bot in this server.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		return
+	}
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
 		handler, ok := command.Handlers[i.ApplicationCommandData().Name]
 		if ok {
 			logger.Log.WithField("command", i.ApplicationCommandData().Name).Info("Handling command")
@@ -56,29 +95,45 @@ func StartBot() error {
 		} else {
 			logger.Log.WithField("command", i.ApplicationCommandData().Name).Error("Command handler not found")
 		}
-	})
-	discord.AddHandler(OnGuildCreate)
-	discord.AddHandler(OnGuildDelete)
-	go services.CheckAccounts(discord)
-	return nil
+	case discordgo.InteractionMessageComponent:
+		customID := i.MessageComponentData().CustomID
+		prefix, _, _ := strings.Cut(customID, ":")
+		handler, ok := command.ComponentHandlers[prefix]
+		if ok {
+			logger.Log.WithField("component", customID).Info("Handling component")
+			handler(s, i)
+		} else {
+			logger.Log.WithField("component", customID).Error("Component handler not found")
+		}
+	case discordgo.InteractionModalSubmit:
+		customID := i.ModalSubmitData().CustomID
+		prefix, _, _ := strings.Cut(customID, ":")
+		handler, ok := command.ModalHandlers[prefix]
+		if ok {
+			logger.Log.WithField("modal", customID).Info("Handling modal")
+			handler(s, i)
+		} else {
+			logger.Log.WithField("modal", customID).Error("Modal handler not found")
+		}
+	}
 }
 
 func OnGuildCreate(s *discordgo.Session, event *discordgo.GuildCreate) {
 	guildID := event.Guild.ID
 	logger.Log.WithField("guild", guildID).Info("Bot joined server:")
-	command.RegisterCommands(s, guildID)
+	command.RegisterCommands(discordapi.Wrap(s), guildID)
 }
 
 func OnGuildDelete(s *discordgo.Session, event *discordgo.GuildDelete) {
 	guildID := event.Guild.ID
 	logger.Log.WithField("guild", guildID).Info("Bot left guild")
-	command.UnregisterCommands(s, guildID)
+	command.UnregisterCommands(discordapi.Wrap(s), guildID)
 }
 
 // Possibly unnecessary; not sure yet. Commenting it out for now.
 /*	func StopBot() error {
 	logger.Log.Info("Bot is shutting down")
-	guilds, err := discord.UserGuilds(100, "", "", false)
+	guilds, err := shards.Guilds()
 	if err != nil {
 		logger.Log.WithError(err).WithField("Bot Shutdown", "Disconnecting Guilds").Error()
 		return err
@@ -86,11 +141,7 @@ func OnGuildDelete(s *discordgo.Session, event *discordgo.GuildDelete) {
 	for _, guild := range guilds {
 		logger.Log.WithField("guild", guild.Name).Info("Disconnected from Guild")
 	}
-	err = discord.Close()
-	if err != nil {
-		logger.Log.WithError(err).WithField("Bot Shutdown", "Closing Session").Error()
-		return err
-	}
+	shards.Close()
 	return nil
 }
 
@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"strconv"
+	"time"
+
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"github.com/bwmarrin/discordgo"
+)
+
+// ShardManager owns one gateway session per shard and routes guilds to the shard
+// Discord delivers their events on.
+type ShardManager struct {
+	sessions       []*discordgo.Session
+	maxConcurrency int // How many shards may identify at once, from Discord's session start limit.
+}
+
+// identifyInterval is how long Discord requires between buckets of identifying shards.
+const identifyInterval = 5 * time.Second
+
+// NewShardManager creates count sessions. When count is 0 the shard count recommended
+// by Discord is used.
+func NewShardManager(token string, count int) (*ShardManager, error) {
+	probe, err := discordgo.New("Bot " + token)
+	if err != nil {
+		return nil, err
+	}
+	maxConcurrency := 1
+	gateway, err := probe.GatewayBot()
+	switch {
+	case err != nil && count <= 0:
+		return nil, err
+	case err != nil:
+		logger.Log.WithError(err).Warn("Failed to fetch the session start limit, identifying one shard at a time")
+	default:
+		if gateway.SessionStartLimit.MaxConcurrency > 1 {
+			maxConcurrency = gateway.SessionStartLimit.MaxConcurrency
+		}
+		if count <= 0 {
+			count = gateway.Shards
+			if count < 1 {
+				count = 1
+			}
+			logger.Log.Infof("Discord recommends %d shard(s)", count)
+		}
+	}
+
+	m := &ShardManager{sessions: make([]*discordgo.Session, count), maxConcurrency: maxConcurrency}
+	for id := range m.sessions {
+		session, err := discordgo.New("Bot " + token)
+		if err != nil {
+			return nil, err
+		}
+		session.ShardID = id
+		session.ShardCount = count
+		m.sessions[id] = session
+	}
+	return m, nil
+}
+
+// Open connects the shards in buckets of maxConcurrency, waiting identifyInterval
+// between buckets to stay within Discord's identify rate limit.
+func (m *ShardManager) Open() error {
+	for start := 0; start < len(m.sessions); start += m.maxConcurrency {
+		if start > 0 {
+			time.Sleep(identifyInterval)
+		}
+		end := start + m.maxConcurrency
+		if end > len(m.sessions) {
+			end = len(m.sessions)
+		}
+		errs := make(chan error, end-start)
+		for _, session := range m.sessions[start:end] {
+			session := session
+			go func() {
+				logger.Log.Infof("Opening shard %d/%d", session.ShardID+1, session.ShardCount)
+				errs <- session.Open()
+			}()
+		}
+		var firstErr error
+		for range m.sessions[start:end] {
+			if err := <-errs; err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		if firstErr != nil {
+			return firstErr
+		}
+	}
+	return nil
+}
+
+func (m *ShardManager) Close() {
+	for _, session := range m.sessions {
+		if err := session.Close(); err != nil {
+			logger.Log.WithError(err).Errorf("Error closing shard %d", session.ShardID)
+		}
+	}
+}
+
+func (m *ShardManager) AddHandler(handler interface{}) {
+	for _, session := range m.sessions {
+		session.AddHandler(handler)
+	}
+}
+
+func (m *ShardManager) UpdateWatchStatus(name string) error {
+	for _, session := range m.sessions {
+		if err := session.UpdateWatchStatus(0, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SessionForGuild returns the session of the shard that guildID belongs to. DMs and
+// an empty guild ID are routed to shard 0, which is the shard Discord uses for them.
+func (m *ShardManager) SessionForGuild(guildID string) discordapi.Session {
+	id, err := strconv.ParseUint(guildID, 10, 64)
+	if err != nil {
+		return discordapi.Wrap(m.sessions[0])
+	}
+	return discordapi.Wrap(m.sessions[(id>>22)%uint64(len(m.sessions))])
+}
+
+// Guilds returns every guild the bot is in, following Discord's pagination.
+func (m *ShardManager) Guilds() ([]*discordgo.UserGuild, error) {
+	const pageSize = 200
+	var guilds []*discordgo.UserGuild
+	after := ""
+	for {
+		page, err := m.sessions[0].UserGuilds(pageSize, "", after, false)
+		if err != nil {
+			return nil, err
+		}
+		guilds = append(guilds, page...)
+		if len(page) < pageSize {
+			return guilds, nil
+		}
+		after = page[len(page)-1].ID
+	}
+}
@@ -0,0 +1,248 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/models"
+	"codstatusbot2.0/services"
+)
+
+type accountRow struct {
+	ID          uint   `json:"id"`
+	Title       string `json:"title"`
+	UserID      string `json:"user_id"`
+	GuildID     string `json:"guild_id"`
+	Status      string `json:"status"`
+	CookieValid bool   `json:"cookie_valid"`
+	LastCheck   string `json:"last_check,omitempty"`
+	PausedUntil string `json:"paused_until,omitempty"`
+}
+
+func runAccounts(args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected list, pause, purge or header-profile")
+	}
+	if _, err := connect(); err != nil {
+		return err
+	}
+	switch args[0] {
+	case "list":
+		return listAccounts(args[1:])
+	case "pause":
+		return pauseAccount(args[1:])
+	case "purge":
+		return purgeAccounts(args[1:])
+	case "header-profile":
+		return setHeaderProfile(args[1:])
+	default:
+		return fmt.Errorf("unknown accounts command %q, expected list, pause, purge or header-profile", args[0])
+	}
+}
+
+func listAccounts(args []string) error {
+	flags := flag.NewFlagSet("accounts list", flag.ContinueOnError)
+	userID := flags.String("user", "", "only accounts of this Discord user ID")
+	guildID := flags.String("guild", "", "only accounts added in this guild ID")
+	asJSON := flags.Bool("json", false, "print JSON instead of a table")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	query := database.DB.Order("id")
+	if *userID != "" {
+		query = query.Where("user_id = ?", *userID)
+	}
+	if *guildID != "" {
+		query = query.Where("guild_id = ?", *guildID)
+	}
+	var accounts []models.Account
+	if err := query.Find(&accounts).Error; err != nil {
+		return err
+	}
+
+	rows := make([]accountRow, len(accounts))
+	for i, account := range accounts {
+		rows[i] = accountRow{
+			ID:          account.ID,
+			Title:       account.Title,
+			UserID:      account.UserID,
+			GuildID:     account.GuildID,
+			Status:      string(account.LastStatus),
+			CookieValid: !account.IsExpiredCookie,
+			LastCheck:   formatTimestamp(account.LastCheck),
+			PausedUntil: formatUntil(account.PausedUntil),
+		}
+	}
+	if *asJSON {
+		return printJSON(rows)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTITLE\tUSER\tGUILD\tSTATUS\tCOOKIE\tLAST CHECK\tPAUSED UNTIL")
+	for _, row := range rows {
+		cookie := "valid"
+		if !row.CookieValid {
+			cookie = "expired"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", row.ID, row.Title, row.UserID, row.GuildID, row.Status, cookie,
+			orDash(row.LastCheck), orDash(row.PausedUntil))
+	}
+	return w.Flush()
+}
+
+func pauseAccount(args []string) error {
+	flags := flag.NewFlagSet("accounts pause", flag.ContinueOnError)
+	duration := flags.String("for", "indefinitely", `how long to pause, e.g. 12h, 3d, "indefinitely" or "off" to resume`)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: accounts pause [--for <duration>] <account id>")
+	}
+	id, err := strconv.ParseUint(flags.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid account id %q", flags.Arg(0))
+	}
+	until, err := services.ParseUntil(*duration, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var account models.Account
+	if err := database.DB.First(&account, id).Error; err != nil {
+		return err
+	}
+	if err := database.DB.Model(&account).Update("paused_until", until).Error; err != nil {
+		return err
+	}
+	if until == 0 {
+		fmt.Printf("Resumed checks for account %d (%s)\n", account.ID, account.Title)
+		return nil
+	}
+	if until == services.Indefinitely {
+		fmt.Printf("Paused account %d (%s) indefinitely\n", account.ID, account.Title)
+		return nil
+	}
+	fmt.Printf("Paused account %d (%s) until %s\n", account.ID, account.Title, formatTimestamp(until))
+	return nil
+}
+
+// setHeaderProfile pins an account to one of the configured header profiles, or with
+// "default" hands it back to the configured default or rotation.
+func setHeaderProfile(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: accounts header-profile <account id> <profile name|default>")
+	}
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid account id %q", args[0])
+	}
+	name := ""
+	if !strings.EqualFold(args[1], "default") {
+		if name, err = services.HeaderProfileName(args[1]); err != nil {
+			return err
+		}
+	}
+
+	var account models.Account
+	if err := database.DB.First(&account, id).Error; err != nil {
+		return err
+	}
+	if err := database.DB.Model(&account).Update("header_profile", name).Error; err != nil {
+		return err
+	}
+	if name == "" {
+		fmt.Printf("Account %d (%s) uses the default header profile again\n", account.ID, account.Title)
+		return nil
+	}
+	fmt.Printf("Account %d (%s) now uses the %s header profile\n", account.ID, account.Title, name)
+	return nil
+}
+
+// purgeAccounts deletes the given accounts, or those whose cookie has been expired
+// without a successful check for a while. Without --yes it only lists them.
+func purgeAccounts(args []string) error {
+	flags := flag.NewFlagSet("accounts purge", flag.ContinueOnError)
+	expiredFor := flags.Duration("expired-for", 0, "purge accounts with an expired cookie and no successful check for this long, e.g. 720h")
+	yes := flags.Bool("yes", false, "delete the accounts instead of listing them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var accounts []models.Account
+	switch {
+	case *expiredFor > 0 && flags.NArg() == 0:
+		cutoff := time.Now().Add(-*expiredFor)
+		err := database.DB.Where("is_expired_cookie = ? AND last_check < ? AND created_at < ?", true, cutoff.Unix(), cutoff).
+			Order("id").Find(&accounts).Error
+		if err != nil {
+			return err
+		}
+	case *expiredFor == 0 && flags.NArg() > 0:
+		ids := make([]uint64, flags.NArg())
+		for i, arg := range flags.Args() {
+			id, err := strconv.ParseUint(arg, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid account id %q", arg)
+			}
+			ids[i] = id
+		}
+		if err := database.DB.Where("id IN ?", ids).Order("id").Find(&accounts).Error; err != nil {
+			return err
+		}
+	default:
+		return errors.New("usage: accounts purge [--yes] (--expired-for <duration> | <account id>...)")
+	}
+
+	if len(accounts) == 0 {
+		fmt.Println("No accounts to purge")
+		return nil
+	}
+	for _, account := range accounts {
+		if !*yes {
+			fmt.Printf("Would purge account %d (%s) of user %s\n", account.ID, account.Title, account.UserID)
+			continue
+		}
+		if err := services.PurgeAccount(account); err != nil {
+			return fmt.Errorf("failed to purge account %d: %w", account.ID, err)
+		}
+		fmt.Printf("Purged account %d (%s)\n", account.ID, account.Title)
+	}
+	if !*yes {
+		fmt.Println("\nRun again with --yes to delete these accounts")
+	}
+	return nil
+}
+
+func formatTimestamp(unix int64) string {
+	if unix == 0 {
+		return ""
+	}
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
+
+func formatUntil(until int64) string {
+	switch {
+	case until == services.Indefinitely:
+		return "indefinitely"
+	case until <= time.Now().Unix():
+		return ""
+	default:
+		return formatTimestamp(until)
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"codstatusbot2.0/config"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/services"
+)
+
+type checkResult struct {
+	Status          string            `json:"status"`
+	Bans            []checkBan        `json:"bans"`
+	CookieExpiresAt string            `json:"cookie_expires_at,omitempty"`
+	Created         string            `json:"created,omitempty"`
+	Age             *checkAge         `json:"age,omitempty"`
+	Username        string            `json:"username,omitempty"`
+	Linked          map[string]string `json:"linked,omitempty"`
+	ProfileError    string            `json:"profile_error,omitempty"`
+}
+
+type checkBan struct {
+	Title       string `json:"title"`
+	Enforcement string `json:"enforcement"`
+	CanAppeal   bool   `json:"can_appeal"`
+}
+
+type checkAge struct {
+	Years  int `json:"years"`
+	Months int `json:"months"`
+	Days   int `json:"days"`
+}
+
+// runCheck checks a single cookie the same way the bot checks an account, without
+// touching the database or Discord.
+func runCheck(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	cookie := flags.String("cookie", "", "the ACT_SSO_COOKIE to check; prefer SSO_COOKIE or stdin, as arguments end up in the shell history")
+	profile := flags.String("profile", "", "the header profile to send the requests with")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *cookie != "" {
+		logger.Log.Warn("--cookie leaves the cookie in the shell history and the process list, pass it in SSO_COOKIE or on stdin instead")
+	} else if *cookie = os.Getenv("SSO_COOKIE"); *cookie == "" {
+		var err error
+		if *cookie, err = readCookie(os.Stdin); err != nil {
+			return err
+		}
+	}
+
+	// Only the request settings are needed, so an incomplete configuration is fine.
+	cfg, err := config.Read()
+	if err != nil {
+		return err
+	}
+	services.Configure(cfg)
+	services.StartHTTP()
+
+	creds := services.Credentials{SSOCookie: *cookie, HeaderProfile: *profile}
+	status, bans, err := services.CheckAccountDetails(creds)
+	if err != nil {
+		return err
+	}
+	result := checkResult{Status: string(status), Bans: make([]checkBan, len(bans))}
+	for i, ban := range bans {
+		result.Bans[i] = checkBan{Title: ban.Title, Enforcement: ban.Enforcement, CanAppeal: ban.CanAppeal}
+	}
+	if expiry, err := services.DecodeSSOCookieExpiry(*cookie); err == nil {
+		result.CookieExpiresAt = expiry.UTC().Format(time.RFC3339)
+	}
+
+	fetched, err := services.FetchProfile(creds)
+	if err != nil {
+		result.ProfileError = err.Error()
+		return printJSON(result)
+	}
+	years, months, days := services.AccountAge(fetched.Created, time.Now())
+	result.Created = fetched.Created.UTC().Format(time.RFC3339)
+	result.Age = &checkAge{Years: years, Months: months, Days: days}
+	result.Username = fetched.Username
+	result.Linked = make(map[string]string)
+	for platform, name := range map[string]string{
+		"battle_net": fetched.BattleNet,
+		"psn":        fetched.PSN,
+		"xbox":       fetched.Xbox,
+		"steam":      fetched.Steam,
+	} {
+		if name != "" {
+			result.Linked[platform] = name
+		}
+	}
+	return printJSON(result)
+}
+
+// readCookie reads the cookie from the first line of stdin, unless stdin is a terminal
+// and nothing was piped in.
+func readCookie(stdin *os.File) (string, error) {
+	if info, err := stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return "", errors.New("no cookie given, set SSO_COOKIE or pipe it in on stdin")
+	}
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if line = strings.TrimSpace(line); line == "" {
+		return "", errors.New("no cookie given, set SSO_COOKIE or pipe it in on stdin")
+	}
+	return line, nil
+}
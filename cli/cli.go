@@ -0,0 +1,91 @@
+// Package cli implements the bot's command line: serving the bot and the operator
+// tools that work without Discord.
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"codstatusbot2.0/config"
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/services"
+)
+
+type subcommand struct {
+	usage string
+	run   func(args []string) error
+}
+
+var subcommands = map[string]subcommand{
+	"serve":    {"serve                                     run the Discord bot (default)", runServe},
+	"check":    {"check [--profile <name>]                  check the SSO cookie in SSO_COOKIE or on stdin and print the result as JSON", runCheck},
+	"migrate":  {"migrate up|status|drop                    manage the database schema", runMigrate},
+	"accounts": {"accounts list|pause|purge|header-profile  list, pause, delete or configure monitored accounts", runAccounts},
+	"export":   {"export [--file <path>]                    write every table to JSON", runExport},
+	"import":   {"import [--file <path>]                    load a JSON export into the database", runImport},
+	"doctor":   {"doctor [--skip-activision]                check the config, database, Discord token and channel permissions", runDoctor},
+}
+
+// Run executes the subcommand named by args[0] and returns the process exit code.
+// Without arguments the bot is served, as it was before subcommands existed.
+func Run(args []string) int {
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage()
+		return 0
+	}
+	cmd, ok := subcommands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		usage()
+		return 2
+	}
+	if args[0] != "serve" {
+		logger.ToStderr()
+	}
+	if err := cmd.run(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func usage() {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString("Usage: codstatusbot <command> [options]\n\nCommands:\n")
+	for _, name := range names {
+		b.WriteString("  " + subcommands[name].usage + "\n")
+	}
+	fmt.Fprint(os.Stderr, b.String())
+}
+
+// connect loads and validates the configuration and opens the database without
+// migrating it.
+func connect() (*config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	services.Configure(cfg)
+	if err := database.Connect(cfg.Database); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
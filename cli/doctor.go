@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sync"
+
+	"codstatusbot2.0/config"
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/models"
+	"codstatusbot2.0/services"
+
+	"github.com/bwmarrin/discordgo"
+	"gorm.io/gorm/schema"
+)
+
+// requiredPermissions are what the bot needs in every channel it posts notifications to.
+var requiredPermissions = []struct {
+	bit  int64
+	name string
+}{
+	{discordgo.PermissionViewChannel, "View Channel"},
+	{discordgo.PermissionSendMessages, "Send Messages"},
+	{discordgo.PermissionEmbedLinks, "Embed Links"},
+}
+
+type doctor struct {
+	failed int
+}
+
+func (d *doctor) ok(format string, args ...interface{}) {
+	fmt.Printf("[ok]   "+format+"\n", args...)
+}
+
+func (d *doctor) fail(format string, args ...interface{}) {
+	d.failed++
+	fmt.Printf("[fail] "+format+"\n", args...)
+}
+
+// runDoctor checks everything the bot needs to run and prints one line per check.
+func runDoctor(args []string) error {
+	flags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	skipActivision := flags.Bool("skip-activision", false, "do not check that the Activision API is reachable")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	d := &doctor{}
+
+	cfg, err := config.Load()
+	if err != nil {
+		d.fail("config: %v", err)
+		return errors.New("the configuration is invalid, fix it before running the other checks")
+	}
+	d.ok("config is valid")
+	services.Configure(cfg)
+
+	databaseOK := d.checkDatabase(cfg)
+	session := d.checkDiscord(cfg)
+	if databaseOK && session != nil {
+		d.checkChannels(session)
+	}
+	if !*skipActivision {
+		if err := services.PingActivision(); err != nil {
+			d.fail("activision: %v", err)
+		} else {
+			d.ok("activision API is reachable")
+		}
+	}
+
+	if d.failed > 0 {
+		return fmt.Errorf("%d check(s) failed", d.failed)
+	}
+	return nil
+}
+
+func (d *doctor) checkDatabase(cfg *config.Config) bool {
+	if err := database.Connect(cfg.Database); err != nil {
+		d.fail("database: %v", err)
+		return false
+	}
+	sqlDB, err := database.DB.DB()
+	if err == nil {
+		err = sqlDB.Ping()
+	}
+	if err != nil {
+		d.fail("database: %v", err)
+		return false
+	}
+	d.ok("database is reachable")
+
+	migrator := database.DB.Migrator()
+	var pending []string
+	for _, model := range database.Models {
+		parsed, err := schema.Parse(model, &sync.Map{}, database.DB.NamingStrategy)
+		if err != nil {
+			d.fail("schema: %v", err)
+			return false
+		}
+		if !migrator.HasTable(model) {
+			pending = append(pending, parsed.Table)
+			continue
+		}
+		for _, field := range parsed.Fields {
+			if field.DBName != "" && !migrator.HasColumn(model, field.DBName) {
+				pending = append(pending, parsed.Table)
+				break
+			}
+		}
+	}
+	if len(pending) > 0 {
+		d.fail("schema: %v need migrating, run migrate up", pending)
+		return false
+	}
+	d.ok("schema is up to date")
+	return true
+}
+
+func (d *doctor) checkDiscord(cfg *config.Config) *discordgo.Session {
+	s, err := discordgo.New("Bot " + cfg.Discord.Token)
+	if err != nil {
+		d.fail("discord: %v", err)
+		return nil
+	}
+	user, err := s.User("@me")
+	if err != nil {
+		d.fail("discord token: %v", err)
+		return nil
+	}
+	s.State.User = user
+	d.ok("discord token belongs to %s#%s", user.Username, user.Discriminator)
+	return s
+}
+
+// checkChannels checks the bot's permissions in every channel notifications can be sent
+// to: the channels accounts were added from and the guilds' alerts channels.
+func (d *doctor) checkChannels(s *discordgo.Session) {
+	channels := make(map[string]bool)
+	var accountChannels []string
+	if err := database.DB.Model(&models.Account{}).Where("notification_type <> ?", "dm").
+		Distinct().Pluck("channel_id", &accountChannels).Error; err != nil {
+		d.fail("channels: %v", err)
+		return
+	}
+	for _, id := range accountChannels {
+		channels[id] = true
+	}
+	var alertChannels []string
+	if err := database.DB.Model(&models.GuildSettings{}).Where("alerts_channel_id <> ?", "").
+		Pluck("alerts_channel_id", &alertChannels).Error; err != nil {
+		d.fail("channels: %v", err)
+		return
+	}
+	for _, id := range alertChannels {
+		channels[id] = true
+	}
+
+	for id := range channels {
+		if id == "" {
+			continue
+		}
+		d.checkChannel(s, id)
+	}
+	if len(channels) == 0 {
+		d.ok("no notification channels to check")
+	}
+}
+
+func (d *doctor) checkChannel(s *discordgo.Session, channelID string) {
+	channel, err := s.Channel(channelID)
+	if err != nil {
+		d.fail("channel %s: %v", channelID, err)
+		return
+	}
+	if _, err := s.State.Guild(channel.GuildID); err != nil {
+		guild, err := s.Guild(channel.GuildID)
+		if err != nil {
+			d.fail("channel %s: guild %s: %v", channelID, channel.GuildID, err)
+			return
+		}
+		s.State.GuildAdd(guild)
+	}
+	if err := s.State.ChannelAdd(channel); err != nil {
+		d.fail("channel %s: %v", channelID, err)
+		return
+	}
+	if _, err := s.State.Member(channel.GuildID, s.State.User.ID); err != nil {
+		member, err := s.GuildMember(channel.GuildID, s.State.User.ID)
+		if err != nil {
+			d.fail("channel %s: the bot is not a member of guild %s: %v", channelID, channel.GuildID, err)
+			return
+		}
+		s.State.MemberAdd(member)
+	}
+
+	perms, err := s.State.UserChannelPermissions(s.State.User.ID, channelID)
+	if err != nil {
+		d.fail("channel %s: %v", channelID, err)
+		return
+	}
+	var missing []string
+	for _, perm := range requiredPermissions {
+		if perms&perm.bit == 0 {
+			missing = append(missing, perm.name)
+		}
+	}
+	if len(missing) > 0 {
+		d.fail("#%s (%s) is missing %v", channel.Name, channelID, missing)
+		return
+	}
+	d.ok("#%s (%s) has the permissions notifications need", channel.Name, channelID)
+}
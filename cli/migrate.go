@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sync"
+
+	"codstatusbot2.0/database"
+
+	"gorm.io/gorm/schema"
+)
+
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("expected up, status or drop")
+	}
+	if _, err := connect(); err != nil {
+		return err
+	}
+	switch args[0] {
+	case "up":
+		if err := database.Migrate(); err != nil {
+			return err
+		}
+		fmt.Println("Schema is up to date")
+		return nil
+	case "status":
+		return migrateStatus()
+	case "drop":
+		return migrateDrop(args[1:])
+	case "down":
+		return errors.New("the schema is not versioned, so there is no down migration; migrate drop --yes drops every table")
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, status or drop", args[0])
+	}
+}
+
+// migrateStatus lists every table with the columns the models need but the database
+// lacks. Running migrate up adds them.
+func migrateStatus() error {
+	migrator := database.DB.Migrator()
+	pending := 0
+	for _, model := range database.Models {
+		parsed, err := schema.Parse(model, &sync.Map{}, database.DB.NamingStrategy)
+		if err != nil {
+			return err
+		}
+		if !migrator.HasTable(model) {
+			fmt.Printf("%-20s missing\n", parsed.Table)
+			pending++
+			continue
+		}
+		var missing []string
+		for _, field := range parsed.Fields {
+			if field.DBName != "" && !migrator.HasColumn(model, field.DBName) {
+				missing = append(missing, field.DBName)
+			}
+		}
+		if len(missing) > 0 {
+			fmt.Printf("%-20s missing columns %v\n", parsed.Table, missing)
+			pending++
+			continue
+		}
+		fmt.Printf("%-20s up to date\n", parsed.Table)
+	}
+	if pending > 0 {
+		fmt.Printf("\n%d table(s) need migrate up\n", pending)
+	}
+	return nil
+}
+
+// migrateDrop drops every table the bot manages, and needs --yes. The schema is not
+// versioned, so there is no down migration that only undoes the latest changes.
+func migrateDrop(args []string) error {
+	flags := flag.NewFlagSet("migrate drop", flag.ContinueOnError)
+	yes := flags.Bool("yes", false, "confirm that every table and all of its data should be dropped")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !*yes {
+		return errors.New("this drops every table and all data, run export first and pass --yes to continue")
+	}
+	migrator := database.DB.Migrator()
+	for i := len(database.Models) - 1; i >= 0; i-- {
+		if err := migrator.DropTable(database.Models[i]); err != nil {
+			return err
+		}
+	}
+	fmt.Println("Dropped every table")
+	return nil
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"codstatusbot2.0/bot"
+	"codstatusbot2.0/config"
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/logger"
+)
+
+func runServe(args []string) error {
+	logger.Log.Info("Bot starting...")
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Log.WithError(err).WithField("Bot Startup", "Configuration").Error()
+		return err
+	}
+
+	err = database.Databaselogin(cfg.Database)
+	if err != nil {
+		logger.Log.WithError(err).WithField("Bot Startup", "Database login").Error()
+		return err
+	}
+	err = bot.StartBot(cfg)
+	if err != nil {
+		logger.Log.WithError(err).WithField("Bot Startup", "Discord login").Error()
+		return err
+	}
+	logger.Log.Info("Bot is running")
+	sc := make(chan os.Signal, 1)
+	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+	<-sc
+	return nil
+}
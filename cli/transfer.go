@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"codstatusbot2.0/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// exportVersion is bumped when the export format changes incompatibly.
+const exportVersion = 1
+
+type exportFile struct {
+	Version    int                        `json:"version"`
+	ExportedAt string                     `json:"exported_at"`
+	Tables     map[string]json.RawMessage `json:"tables"`
+}
+
+// runExport writes every row of every table, including soft deleted ones, as JSON.
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	path := flags.String("file", "", "write to this file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if _, err := connect(); err != nil {
+		return err
+	}
+
+	export := exportFile{Version: exportVersion, ExportedAt: time.Now().UTC().Format(time.RFC3339), Tables: make(map[string]json.RawMessage)}
+	for _, model := range database.Models {
+		table, rows, err := modelRows(model)
+		if err != nil {
+			return err
+		}
+		if err := database.DB.Unscoped().Find(rows.Interface()).Error; err != nil {
+			return fmt.Errorf("failed to read %s: %w", table, err)
+		}
+		data, err := json.Marshal(rows.Interface())
+		if err != nil {
+			return err
+		}
+		export.Tables[table] = data
+	}
+
+	out := io.Writer(os.Stdout)
+	if *path != "" {
+		file, err := os.OpenFile(*path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	fmt.Fprintln(os.Stderr, "The export contains every stored SSO cookie, keep it private")
+	return json.NewEncoder(out).Encode(export)
+}
+
+// runImport loads an export, inserting rows that do not exist and overwriting those
+// that do, in a single transaction.
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	path := flags.String("file", "", "read from this file instead of stdin")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	in := io.Reader(os.Stdin)
+	if *path != "" {
+		file, err := os.Open(*path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	var export exportFile
+	if err := json.NewDecoder(in).Decode(&export); err != nil {
+		return fmt.Errorf("failed to read export: %w", err)
+	}
+	if export.Version != exportVersion {
+		return fmt.Errorf("unsupported export version %d", export.Version)
+	}
+
+	if _, err := connect(); err != nil {
+		return err
+	}
+	if err := database.Migrate(); err != nil {
+		return err
+	}
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range database.Models {
+			table, rows, err := modelRows(model)
+			if err != nil {
+				return err
+			}
+			data, ok := export.Tables[table]
+			if !ok {
+				continue
+			}
+			if err := json.Unmarshal(data, rows.Interface()); err != nil {
+				return fmt.Errorf("failed to decode %s: %w", table, err)
+			}
+			count := rows.Elem().Len()
+			if count == 0 {
+				continue
+			}
+			err = tx.Omit(clause.Associations).Clauses(clause.OnConflict{UpdateAll: true}).
+				CreateInBatches(rows.Interface(), 100).Error
+			if err != nil {
+				return fmt.Errorf("failed to import %s: %w", table, err)
+			}
+			fmt.Printf("Imported %d row(s) into %s\n", count, table)
+		}
+		return nil
+	})
+}
+
+// modelRows returns the table name of a model and a pointer to an empty slice of it.
+func modelRows(model interface{}) (string, reflect.Value, error) {
+	parsed, err := schema.Parse(model, &sync.Map{}, database.DB.NamingStrategy)
+	if err != nil {
+		return "", reflect.Value{}, err
+	}
+	typ := reflect.TypeOf(model)
+	if typ.Kind() != reflect.Ptr {
+		return "", reflect.Value{}, errors.New("models must be pointers")
+	}
+	return parsed.Table, reflect.New(reflect.SliceOf(typ.Elem())), nil
+}
@@ -0,0 +1,306 @@
+package accountactions
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"codstatusbot2.0/command/accountlogs"
+	"codstatusbot2.0/command/consent"
+	"codstatusbot2.0/command/removeaccount"
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+	"codstatusbot2.0/services"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	snoozeDuration = 24 * time.Hour
+	// recheckTimeout is how long the Recheck now button waits for the scheduler to
+	// check the account before answering that the check is queued.
+	recheckTimeout = 30 * time.Second
+)
+
+func HandleRecheck(s discordapi.Session, i *discordgo.InteractionCreate) {
+	account, ok := ownedAccount(s, i, i.MessageComponentData().CustomID)
+	if !ok {
+		return
+	}
+	if account.IsExpiredCookie {
+		respond(s, i, fmt.Sprintf("The SSO cookie for account %s has expired. Use the Update cookie button or /updateaccount to continue monitoring it.", account.Title))
+		return
+	}
+	requestedAt := time.Now().Unix()
+	err := services.RequestRecheck(account)
+	switch {
+	case errors.Is(err, services.ErrAccountPaused):
+		respond(s, i, fmt.Sprintf("Account %s is paused %s. Use /pause to resume it before rechecking.", account.Title, services.FormatUntil(account.PausedUntil)))
+		return
+	case errors.Is(err, services.ErrRecheckCooldown):
+		respond(s, i, fmt.Sprintf("Account %s was rechecked recently. You can recheck it again <t:%d:R>.", account.Title, account.LastRecheckAt+int64(services.RecheckCooldown.Seconds())))
+		return
+	case err != nil:
+		logger.Log.WithError(err).Errorf("Error requesting recheck of account %s", account.Title)
+		respond(s, i, "Error rechecking the account")
+		return
+	}
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+
+	account, checked := services.WaitForCheck(account.ID, requestedAt, recheckTimeout)
+	if !checked {
+		sendFollowUpMessage(s, i, fmt.Sprintf("The recheck of account %s is queued. You will be notified if its status changes.", account.Title))
+		return
+	}
+	content := fmt.Sprintf("Account %s is currently %s.", account.Title, account.LastStatus)
+	if account.IsExpiredCookie {
+		content = fmt.Sprintf("The SSO cookie for account %s has expired. Use the Update cookie button or /updateaccount to continue monitoring it.", account.Title)
+	}
+	sendFollowUpMessage(s, i, content)
+}
+
+func HandleUpdate(s discordapi.Session, i *discordgo.InteractionCreate) {
+	account, ok := ownedAccount(s, i, i.MessageComponentData().CustomID)
+	if !ok {
+		return
+	}
+	if !consent.RequireConsent(s, i) {
+		return
+	}
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: services.ActionCustomID(services.ActionUpdateModal, account.ID),
+			Title:    fmt.Sprintf("Update cookie - %s", truncate(account.Title, 25)),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    "sso_cookie",
+							Label:       "New SSO cookie",
+							Style:       discordgo.TextInputParagraph,
+							Placeholder: "Paste the value of ACT_SSO_COOKIE",
+							Required:    true,
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		logger.Log.WithError(err).Error("Error opening update cookie modal")
+	}
+}
+
+func HandleUpdateModal(s discordapi.Session, i *discordgo.InteractionCreate) {
+	data := i.ModalSubmitData()
+	account, ok := ownedAccount(s, i, data.CustomID)
+	if !ok {
+		return
+	}
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+
+	newSSOCookie := strings.TrimSpace(textInputValue(data, "sso_cookie"))
+	if newSSOCookie == "" || !services.VerifySSOCookie(newSSOCookie) {
+		sendFollowUpMessage(s, i, "Invalid new SSO cookie")
+		return
+	}
+
+	sealedCookie, err := services.SealCookie(newSSOCookie)
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Error encrypting new cookie for account %s", account.Title)
+		sendFollowUpMessage(s, i, "Error updating the SSO cookie")
+		return
+	}
+	// Only the cookie columns are written, the row may have changed while the cookie
+	// was being verified.
+	err = database.DB.Model(&account).Updates(map[string]interface{}{
+		"sso_cookie":               sealedCookie,
+		"last_status":              models.StatusUnknown,
+		"is_expired_cookie":        false,
+		"last_cookie_notification": 0,
+		"cookie_expires_at":        services.CookieExpiresAt(newSSOCookie),
+		"cookie_warning_level":     0,
+	}).Error
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Error saving new cookie for account %s", account.Title)
+		sendFollowUpMessage(s, i, "Error updating the SSO cookie")
+		return
+	}
+	services.EnqueuePriorityCheck(account.ID)
+	sendFollowUpMessage(s, i, "Account SSO cookie updated")
+}
+
+func HandleHistory(s discordapi.Session, i *discordgo.InteractionCreate) {
+	account, ok := ownedAccount(s, i, i.MessageComponentData().CustomID)
+	if !ok {
+		return
+	}
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{accountlogs.LogsEmbed(account)},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func HandleSnooze(s discordapi.Session, i *discordgo.InteractionCreate) {
+	account, ok := ownedAccount(s, i, i.MessageComponentData().CustomID)
+	if !ok {
+		return
+	}
+	until := time.Now().Add(snoozeDuration).Unix()
+	// A longer mute, including an indefinite one, is left as it is.
+	result := database.DB.Model(&account).Where("muted_until < ?", until).Update("muted_until", until)
+	if result.Error != nil {
+		logger.Log.WithError(result.Error).Errorf("Error snoozing account %s", account.Title)
+		respond(s, i, "Error snoozing notifications")
+		return
+	}
+	if result.RowsAffected == 0 {
+		respond(s, i, fmt.Sprintf("Account %s is already muted %s. Use /mute to change it.", account.Title, services.FormatUntil(account.MutedUntil)))
+		return
+	}
+	alerts := "Ban alerts will still be sent."
+	if account.MuteBanAlerts {
+		alerts = "Ban alerts are muted too, as set with /mute."
+	}
+	respond(s, i, fmt.Sprintf("Account %s is left out of your digest and its cookie reminders are snoozed until <t:%d:f>. %s", account.Title, until, alerts))
+}
+
+func HandleStop(s discordapi.Session, i *discordgo.InteractionCreate) {
+	account, ok := ownedAccount(s, i, i.MessageComponentData().CustomID)
+	if !ok {
+		return
+	}
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("Stop monitoring %s? This permanently deletes the account and its history and cannot be undone.", account.Title),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{Label: "Stop monitoring", Style: discordgo.DangerButton, CustomID: services.ActionCustomID(services.ActionStopConfirm, account.ID)},
+						discordgo.Button{Label: "Cancel", Style: discordgo.SecondaryButton, CustomID: services.ActionCustomID(services.ActionStopCancel, account.ID)},
+					},
+				},
+			},
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func HandleStopConfirm(s discordapi.Session, i *discordgo.InteractionCreate) {
+	account, ok := ownedAccount(s, i, i.MessageComponentData().CustomID)
+	if !ok {
+		return
+	}
+	content := fmt.Sprintf("Account %s removed", account.Title)
+	if err := removeaccount.DeleteAccount(account.UserID, account.GuildID, account.ID); err != nil {
+		content = "Error removing account"
+	}
+	updateMessage(s, i, content)
+	removeaccount.UpdateAccountChoices(s, account.GuildID)
+}
+
+func HandleStopCancel(s discordapi.Session, i *discordgo.InteractionCreate) {
+	updateMessage(s, i, "The account is still being monitored.")
+}
+
+// ownedAccount loads the account referenced by a custom ID of the form
+// "<action>:<account id>" and checks that it belongs to the user who clicked.
+func ownedAccount(s discordapi.Session, i *discordgo.InteractionCreate, customID string) (models.Account, bool) {
+	userID := interactionUserID(i)
+	var account models.Account
+	_, idPart, _ := strings.Cut(customID, ":")
+	accountId, err := strconv.ParseUint(idPart, 10, 64)
+	if err == nil {
+		err = database.DB.Where("id = ? AND user_id = ?", accountId, userID).First(&account).Error
+	}
+	if err != nil {
+		logger.Log.WithFields(map[string]interface{}{
+			"custom_id": customID,
+			"user_id":   userID,
+		}).Warn("User tried to use an action on an account they don't own")
+		respond(s, i, "This account no longer exists or you don't own it.")
+		return account, false
+	}
+	return account, true
+}
+
+func textInputValue(data discordgo.ModalSubmitInteractionData, customID string) string {
+	for _, component := range data.Components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, rowComponent := range row.Components {
+			if input, ok := rowComponent.(*discordgo.TextInput); ok && input.CustomID == customID {
+				return input.Value
+			}
+		}
+	}
+	return ""
+}
+
+func respond(s discordapi.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func updateMessage(s discordapi.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		logger.Log.WithError(err).Error("Error updating confirmation message")
+	}
+}
+
+func sendFollowUpMessage(s discordapi.Session, i *discordgo.InteractionCreate, content string) {
+	_, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Content: content,
+		Flags:   discordgo.MessageFlagsEphemeral,
+	})
+	if err != nil {
+		logger.Log.WithError(err).Error("Error sending follow-up message")
+	}
+}
+
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil {
+		return i.Member.User.ID
+	}
+	return i.User.ID
+}
+
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length])
+}
@@ -2,15 +2,17 @@ package accountage
 
 import (
 	"fmt"
+	"time"
 
 	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
 	"codstatusbot2.0/logger"
 	"codstatusbot2.0/models"
 	"codstatusbot2.0/services"
 	"github.com/bwmarrin/discordgo"
 )
 
-func RegisterCommand(s *discordgo.Session, guildID string) {
+func RegisterCommand(s discordapi.Session, guildID string) {
 	commands := []*discordgo.ApplicationCommand{
 		{
 			Name:        "accountage",
@@ -23,11 +25,16 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 					Required:    true,
 					Choices:     getAllChoices(guildID),
 				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "refresh",
+					Description: "Fetch the creation date from Activision again instead of using the stored one",
+				},
 			},
 		},
 	}
 
-	existingCommands, err := s.ApplicationCommands(s.State.User.ID, guildID)
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error getting application commands")
 		return
@@ -45,14 +52,14 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 
 	if existingCommand != nil {
 		logger.Log.Info("Updating accountage command")
-		_, err = s.ApplicationCommandEdit(s.State.User.ID, guildID, existingCommand.ID, newCommand)
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
 		if err != nil {
 			logger.Log.WithError(err).Error("Error updating accountage command")
 			return
 		}
 	} else {
 		logger.Log.Info("Creating accountage command")
-		_, err = s.ApplicationCommandCreate(s.State.User.ID, guildID, newCommand)
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
 		if err != nil {
 			logger.Log.WithError(err).Error("Error creating accountage command")
 			return
@@ -60,8 +67,8 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 	}
 }
 
-func UnregisterCommand(s *discordgo.Session, guildID string) {
-	commands, err := s.ApplicationCommands(s.State.User.ID, guildID)
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error getting application commands")
 		return
@@ -69,7 +76,7 @@ func UnregisterCommand(s *discordgo.Session, guildID string) {
 
 	for _, command := range commands {
 		logger.Log.Infof("Deleting command %s", command.Name)
-		err := s.ApplicationCommandDelete(s.State.User.ID, guildID, command.ID)
+		err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
 		if err != nil {
 			logger.Log.WithError(err).Errorf("Error deleting command %s", command.Name)
 			return
@@ -77,9 +84,15 @@ func UnregisterCommand(s *discordgo.Session, guildID string) {
 	}
 }
 
-func CommandAccountAge(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func CommandAccountAge(s discordapi.Session, i *discordgo.InteractionCreate) {
 	userID := i.Member.User.ID
 	accountId := i.ApplicationCommandData().Options[0].IntValue()
+	refresh := false
+	for _, option := range i.ApplicationCommandData().Options[1:] {
+		if option.Name == "refresh" {
+			refresh = option.BoolValue()
+		}
+	}
 
 	var account models.Account
 	result := database.DB.Where("id = ?", accountId).First(&account)
@@ -110,38 +123,46 @@ func CommandAccountAge(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		return
 	}
 
-	if !services.VerifySSOCookie(account.SSOCookie) {
-		account.IsExpiredCookie = true // Update account's IsExpiredCookie flag
-		database.DB.Save(&account)
+	if refresh || account.ActivisionCreated == 0 {
+		if !services.VerifySSOCookie(account.SSOCookie) {
+			account.IsExpiredCookie = true // Update account's IsExpiredCookie flag
+			database.DB.Save(&account)
 
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "Invalid SSOCookie. Account's cookie status updated.",
-				Flags:   discordgo.MessageFlagsEphemeral,
-			},
-		})
-		return
-	}
+			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "Invalid SSOCookie. Account's cookie status updated.",
+					Flags:   discordgo.MessageFlagsEphemeral,
+				},
+			})
+			return
+		}
 
-	years, months, days, err := services.CheckAccountAge(account.SSOCookie)
-	if err != nil {
-		logger.Log.WithError(err).Errorf("Error checking account age for account %s", account.Title)
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "There was an error checking the account age.",
-				Flags:   discordgo.MessageFlagsEphemeral,
-			},
-		})
-		return
+		if _, err := services.RefreshProfile(&account); err != nil {
+			logger.Log.WithError(err).Errorf("Error checking account age for account %s", account.Title)
+			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "There was an error checking the account age.",
+					Flags:   discordgo.MessageFlagsEphemeral,
+				},
+			})
+			return
+		}
 	}
 
+	years, months, days := services.AccountAge(time.Unix(account.ActivisionCreated, 0), time.Now())
 	embed := &discordgo.MessageEmbed{
 		Title:       fmt.Sprintf("%s - %s", account.Title, account.LastStatus),
-		Description: fmt.Sprintf("The account is %d years, %d months, and %d days old.", years, months, days),
+		Description: fmt.Sprintf("The account is %d years, %d months, and %d days old. It was created on <t:%d:D>.", years, months, days, account.ActivisionCreated),
 		Color:       0x00ff00,
 	}
+	if profile, ok := services.GetAccountProfile(account.ID); ok {
+		embed.Fields = []*discordgo.MessageEmbedField{
+			{Name: "Activision username", Value: profile.Username},
+			{Name: "Linked accounts", Value: services.LinkedAccounts(profile)},
+		}
+	}
 
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
@@ -4,12 +4,14 @@ import (
 	"fmt"
 
 	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
 	"codstatusbot2.0/logger"
 	"codstatusbot2.0/models"
+	"codstatusbot2.0/services"
 	"github.com/bwmarrin/discordgo"
 )
 
-func RegisterCommand(s *discordgo.Session, guildID string) {
+func RegisterCommand(s discordapi.Session, guildID string) {
 	commands := []*discordgo.ApplicationCommand{
 		{
 			Name:        "accountlogs",
@@ -26,7 +28,7 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 		},
 	}
 
-	existingCommands, err := s.ApplicationCommands(s.State.User.ID, guildID)
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error getting application commands")
 		return
@@ -44,14 +46,14 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 
 	if existingCommand != nil {
 		logger.Log.Info("Updating accountlogs command")
-		_, err = s.ApplicationCommandEdit(s.State.User.ID, guildID, existingCommand.ID, newCommand)
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
 		if err != nil {
 			logger.Log.WithError(err).Error("Error updating accountlogs command")
 			return
 		}
 	} else {
 		logger.Log.Info("Creating accountlogs command")
-		_, err = s.ApplicationCommandCreate(s.State.User.ID, guildID, newCommand)
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
 		if err != nil {
 			logger.Log.WithError(err).Error("Error creating accountlogs command")
 			return
@@ -59,8 +61,8 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 	}
 }
 
-func UnregisterCommand(s *discordgo.Session, guildID string) {
-	commands, err := s.ApplicationCommands(s.State.User.ID, guildID)
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error getting application commands")
 		return
@@ -68,7 +70,7 @@ func UnregisterCommand(s *discordgo.Session, guildID string) {
 
 	for _, command := range commands {
 		logger.Log.Infof("Deleting command %s", command.Name)
-		err := s.ApplicationCommandDelete(s.State.User.ID, guildID, command.ID)
+		err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
 		if err != nil {
 			logger.Log.WithError(err).Errorf("Error deleting command %s", command.Name)
 			return
@@ -76,7 +78,7 @@ func UnregisterCommand(s *discordgo.Session, guildID string) {
 	}
 }
 
-func CommandAccountLogs(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func CommandAccountLogs(s discordapi.Session, i *discordgo.InteractionCreate) {
 	userID := i.Member.User.ID
 	accountId := i.ApplicationCommandData().Options[0].IntValue()
 
@@ -91,8 +93,19 @@ func CommandAccountLogs(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		return
 	}
 
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{LogsEmbed(account)},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// LogsEmbed builds the embed listing the last five status changes of an account.
+func LogsEmbed(account models.Account) *discordgo.MessageEmbed {
 	var logs []models.Ban
-	database.DB.Where("account_id = ?", accountId).Order("created_at desc").Limit(5).Find(&logs)
+	database.DB.Where("account_id = ?", account.ID).Order("created_at desc").Limit(5).Find(&logs)
 
 	embed := &discordgo.MessageEmbed{
 		Title:       fmt.Sprintf("%s - %s", account.Title, account.LastStatus),
@@ -104,18 +117,29 @@ func CommandAccountLogs(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	for i, log := range logs {
 		embed.Fields[i] = &discordgo.MessageEmbedField{
 			Name:   string(log.Status),
-			Value:  log.CreatedAt.String(),
+			Value:  fmt.Sprintf("<t:%d:f> (<t:%d:R>)", log.CreatedAt.Unix(), log.CreatedAt.Unix()),
 			Inline: false,
 		}
 	}
 
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Embeds: []*discordgo.MessageEmbed{embed},
-			Flags:  discordgo.MessageFlagsEphemeral,
-		},
-	})
+	var bans []models.GameBan
+	database.DB.Where("account_id = ?", account.ID).Order("created_at desc").Limit(5).Find(&bans)
+	if len(bans) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Game bans",
+			Value: services.GameBanSummary(bans),
+		})
+	}
+
+	var audits []models.AuditLog
+	database.DB.Where("account_id = ?", account.ID).Order("created_at desc").Limit(3).Find(&audits)
+	for _, audit := range audits {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  audit.Detail,
+			Value: fmt.Sprintf("<t:%d:f> (<t:%d:R>)", audit.CreatedAt.Unix(), audit.CreatedAt.Unix()),
+		})
+	}
+	return embed
 }
 
 func getAllChoices(guildID string) []*discordgo.ApplicationCommandOptionChoice {
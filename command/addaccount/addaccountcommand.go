@@ -1,15 +1,19 @@
 package addaccount
 
 import (
+	"fmt"
+
+	"codstatusbot2.0/command/consent"
 	"codstatusbot2.0/command/removeaccount"
 	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
 	"codstatusbot2.0/logger"
 	"codstatusbot2.0/models"
 	"codstatusbot2.0/services"
 	"github.com/bwmarrin/discordgo"
 )
 
-func RegisterCommand(s *discordgo.Session, guildID string) {
+func RegisterCommand(s discordapi.Session, guildID string) {
 	commands := []*discordgo.ApplicationCommand{
 		{
 			Name:        "addaccount",
@@ -31,7 +35,7 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 		},
 	}
 
-	existingCommands, err := s.ApplicationCommands(s.State.User.ID, guildID)
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error getting application commands")
 		return
@@ -49,14 +53,14 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 
 	if existingCommand != nil {
 		logger.Log.Info("Updating addaccount command")
-		_, err = s.ApplicationCommandEdit(s.State.User.ID, guildID, existingCommand.ID, newCommand)
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
 		if err != nil {
 			logger.Log.WithError(err).Error("Error updating addaccount command")
 			return
 		}
 	} else {
 		logger.Log.Info("Creating addaccount command")
-		_, err = s.ApplicationCommandCreate(s.State.User.ID, guildID, newCommand)
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
 		if err != nil {
 			logger.Log.WithError(err).Error("Error creating addaccount command")
 			return
@@ -64,8 +68,8 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 	}
 }
 
-func UnregisterCommand(s *discordgo.Session, guildID string) {
-	commands, err := s.ApplicationCommands(s.State.User.ID, guildID)
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error getting application commands")
 		return
@@ -73,7 +77,7 @@ func UnregisterCommand(s *discordgo.Session, guildID string) {
 
 	for _, command := range commands {
 		logger.Log.Infof("Deleting command %s", command.Name)
-		err := s.ApplicationCommandDelete(s.State.User.ID, guildID, command.ID)
+		err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
 		if err != nil {
 			logger.Log.WithError(err).Errorf("Error deleting command %s", command.Name)
 			return
@@ -81,9 +85,13 @@ func UnregisterCommand(s *discordgo.Session, guildID string) {
 	}
 }
 
-func CommandAddAccount(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func CommandAddAccount(s discordapi.Session, i *discordgo.InteractionCreate) {
 	logger.Log.Info("Invoked addaccount command")
 
+	if !consent.RequireConsent(s, i) {
+		return
+	}
+
 	title := i.ApplicationCommandData().Options[0].StringValue()
 	ssoCookie := i.ApplicationCommandData().Options[1].StringValue()
 
@@ -93,7 +101,6 @@ func CommandAddAccount(s *discordgo.Session, i *discordgo.InteractionCreate) {
 
 	logger.Log.WithFields(map[string]interface{}{
 		"title":      title,
-		"sso_cookie": ssoCookie,
 		"guild_id":   guildID,
 		"channel_id": channelID,
 		"user_id":    userID,
@@ -112,6 +119,22 @@ func CommandAddAccount(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		return
 	}
 
+	settings := services.GetGuildSettings(guildID)
+	if settings.AccountLimit > 0 {
+		var count int64
+		database.DB.Model(&models.Account{}).Where("user_id = ? AND guild_id = ?", userID, guildID).Count(&count)
+		if count >= int64(settings.AccountLimit) {
+			s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: fmt.Sprintf("You can only add %d accounts in this server", settings.AccountLimit),
+					Flags:   discordgo.MessageFlagsEphemeral,
+				},
+			})
+			return
+		}
+	}
+
 	isValid := services.VerifySSOCookie(ssoCookie)
 	if !isValid {
 		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
@@ -128,9 +151,10 @@ func CommandAddAccount(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		UserID:           userID,
 		Title:            title,
 		SSOCookie:        ssoCookie,
+		CookieExpiresAt:  services.CookieExpiresAt(ssoCookie),
 		GuildID:          guildID,
 		ChannelID:        channelID,
-		NotificationType: "channel",
+		NotificationType: settings.DefaultNotificationType,
 	}
 
 	result = database.DB.Create(&account)
@@ -155,7 +179,6 @@ func CommandAddAccount(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	})
 
 	removeaccount.UpdateAccountChoices(s, guildID)
-	// unnecessary to check account status immediately after adding it causes a double response when first adding an account
-	// services.CheckSingleAccount(account, s)
+	services.EnqueuePriorityCheck(account.ID)
 
 }
@@ -0,0 +1,153 @@
+package appeal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+	"codstatusbot2.0/services"
+	"github.com/bwmarrin/discordgo"
+)
+
+func RegisterCommand(s discordapi.Session, guildID string) {
+	commands := []*discordgo.ApplicationCommand{
+		{
+			Name:        "appeal",
+			Description: "Record that you submitted a ban appeal so the bot can report the outcome",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionType(discordgo.InteractionApplicationCommandAutocomplete),
+					Name:        "account",
+					Description: "The title of the account",
+					Required:    true,
+					Choices:     getAllChoices(guildID),
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "game",
+					Description: "The game you appealed the ban for, all active bans if left empty",
+					Required:    false,
+				},
+			},
+		},
+	}
+
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	var existingCommand *discordgo.ApplicationCommand
+	for _, command := range existingCommands {
+		if command.Name == "appeal" {
+			existingCommand = command
+			break
+		}
+	}
+
+	newCommand := commands[0]
+
+	if existingCommand != nil {
+		logger.Log.Info("Updating appeal command")
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error updating appeal command")
+			return
+		}
+	} else {
+		logger.Log.Info("Creating appeal command")
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error creating appeal command")
+			return
+		}
+	}
+}
+
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	for _, command := range commands {
+		if command.Name == "appeal" {
+			logger.Log.Infof("Deleting command %s", command.Name)
+			err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
+			if err != nil {
+				logger.Log.WithError(err).Errorf("Error deleting command %s", command.Name)
+				return
+			}
+		}
+	}
+}
+
+func CommandAppeal(s discordapi.Session, i *discordgo.InteractionCreate) {
+	userID := i.Member.User.ID
+	guildID := i.GuildID
+	options := i.ApplicationCommandData().Options
+	accountId := options[0].IntValue()
+	game := ""
+	if len(options) > 1 {
+		game = strings.TrimSpace(options[1].StringValue())
+	}
+
+	var account models.Account
+	result := database.DB.Where("user_id = ? AND id = ? AND guild_id = ?", userID, accountId, guildID).First(&account)
+	if result.Error != nil {
+		logger.Log.WithError(result.Error).Error("Error retrieving account")
+		respond(s, i, "Account does not exist")
+		return
+	}
+
+	appealed, err := services.RecordAppeal(account.ID, game)
+	if errors.Is(err, services.ErrNoActiveBan) {
+		if game != "" {
+			respond(s, i, fmt.Sprintf("%s has no active ban for %s. Use /accountlogs to see its bans.", account.Title, game))
+		} else {
+			respond(s, i, fmt.Sprintf("%s has no active bans to appeal.", account.Title))
+		}
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Error recording appeal for account %s", account.Title)
+		respond(s, i, "Error recording the appeal")
+		return
+	}
+
+	titles := make([]string, len(appealed))
+	for i, ban := range appealed {
+		titles[i] = ban.Title
+	}
+	respond(s, i, fmt.Sprintf("Appeal recorded for %s on %s. You will be notified when the ban is lifted or made final.", account.Title, strings.Join(titles, ", ")))
+}
+
+func respond(s discordapi.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func getAllChoices(guildID string) []*discordgo.ApplicationCommandOptionChoice {
+	logger.Log.Info("Getting all choices for account select dropdown")
+	var accounts []models.Account
+	database.DB.Where("guild_id = ?", guildID).Find(&accounts)
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, len(accounts))
+	for i, account := range accounts {
+		choices[i] = &discordgo.ApplicationCommandOptionChoice{
+			Name:  account.Title,
+			Value: account.ID,
+		}
+	}
+	return choices
+}
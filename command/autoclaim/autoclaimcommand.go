@@ -0,0 +1,137 @@
+package autoclaim
+
+import (
+	"fmt"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+	"github.com/bwmarrin/discordgo"
+)
+
+func RegisterCommand(s discordapi.Session, guildID string) {
+	commands := []*discordgo.ApplicationCommand{
+		{
+			Name:        "autoclaim",
+			Description: "Claim newly published reward codes for an account automatically",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionType(discordgo.InteractionApplicationCommandAutocomplete),
+					Name:        "account",
+					Description: "The title of the account",
+					Required:    true,
+					Choices:     getAllChoices(guildID),
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "enabled",
+					Description: "Turn automatic claiming on or off",
+					Required:    true,
+				},
+			},
+		},
+	}
+
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	var existingCommand *discordgo.ApplicationCommand
+	for _, command := range existingCommands {
+		if command.Name == "autoclaim" {
+			existingCommand = command
+			break
+		}
+	}
+
+	newCommand := commands[0]
+
+	if existingCommand != nil {
+		logger.Log.Info("Updating autoclaim command")
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error updating autoclaim command")
+			return
+		}
+	} else {
+		logger.Log.Info("Creating autoclaim command")
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error creating autoclaim command")
+			return
+		}
+	}
+}
+
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	for _, command := range commands {
+		if command.Name == "autoclaim" {
+			logger.Log.Infof("Deleting command %s", command.Name)
+			err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
+			if err != nil {
+				logger.Log.WithError(err).Errorf("Error deleting command %s", command.Name)
+				return
+			}
+		}
+	}
+}
+
+func CommandAutoClaim(s discordapi.Session, i *discordgo.InteractionCreate) {
+	userID := i.Member.User.ID
+	guildID := i.GuildID
+	accountId := i.ApplicationCommandData().Options[0].IntValue()
+	enabled := i.ApplicationCommandData().Options[1].BoolValue()
+
+	var account models.Account
+	result := database.DB.Where("user_id = ? AND id = ? AND guild_id = ?", userID, accountId, guildID).First(&account)
+	if result.Error != nil {
+		logger.Log.WithError(result.Error).Error("Error retrieving account")
+		respond(s, i, "Account does not exist")
+		return
+	}
+
+	if err := database.DB.Model(&account).Update("auto_claim_rewards", enabled).Error; err != nil {
+		logger.Log.WithError(err).Errorf("Error saving auto-claim setting for account %s", account.Title)
+		respond(s, i, "Error saving the auto-claim setting")
+		return
+	}
+
+	if !enabled {
+		respond(s, i, fmt.Sprintf("New reward codes will no longer be claimed automatically for %s.", account.Title))
+		return
+	}
+	respond(s, i, fmt.Sprintf("New reward codes will be claimed automatically for %s and you will get a DM with what was unlocked. Use /claimavailablerewards to claim codes published before now.", account.Title))
+}
+
+func respond(s discordapi.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func getAllChoices(guildID string) []*discordgo.ApplicationCommandOptionChoice {
+	logger.Log.Info("Getting all choices for account select dropdown")
+	var accounts []models.Account
+	database.DB.Where("guild_id = ?", guildID).Find(&accounts)
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, len(accounts))
+	for i, account := range accounts {
+		choices[i] = &discordgo.ApplicationCommandOptionChoice{
+			Name:  account.Title,
+			Value: account.ID,
+		}
+	}
+	return choices
+}
@@ -2,16 +2,16 @@ package claimrewards
 
 import (
 	"fmt"
-	"strings"
 
 	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
 	"codstatusbot2.0/logger"
 	"codstatusbot2.0/models"
 	"codstatusbot2.0/services"
 	"github.com/bwmarrin/discordgo"
 )
 
-func RegisterCommand(s *discordgo.Session, guildID string) {
+func RegisterCommand(s discordapi.Session, guildID string) {
 	commands := []*discordgo.ApplicationCommand{
 		{
 			Name:        "claimavailablerewards",
@@ -28,7 +28,7 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 		},
 	}
 
-	existingCommands, err := s.ApplicationCommands(s.State.User.ID, guildID)
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error getting application commands from guild")
 		return
@@ -44,14 +44,14 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 	newCommand := commands[0]
 	if existingCommand != nil {
 		logger.Log.Info("Updating claimavailablerewards command")
-		_, err = s.ApplicationCommandEdit(s.State.User.ID, guildID, existingCommand.ID, newCommand)
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
 		if err != nil {
 			logger.Log.WithError(err).Error("Error updating the claimavailablerewards command")
 			return
 		}
 	} else {
 		logger.Log.Info("Adding the claimavailablerewards command")
-		_, err = s.ApplicationCommandCreate(s.State.User.ID, guildID, newCommand)
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
 	}
 	if err != nil {
 		logger.Log.WithError(err).Error("Error adding the claimavailablerewards command")
@@ -59,24 +59,26 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 	}
 }
 
-func UnregisterCommand(s *discordgo.Session, guildID string) {
-	commands, err := s.ApplicationCommands(s.State.User.ID, guildID)
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error getting application commands")
 		return
 	}
 
 	for _, command := range commands {
-		logger.Log.Infof("Deleting command %s", command.Name)
-		err := s.ApplicationCommandDelete(s.State.User.ID, guildID, command.ID)
-		if err != nil {
-			logger.Log.WithError(err).Errorf("Error unregistering the command %s", command.Name)
-			continue
+		if command.Name == "claimavailablerewards" {
+			logger.Log.Infof("Deleting command %s", command.Name)
+			err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
+			if err != nil {
+				logger.Log.WithError(err).Errorf("Error unregistering the command %s", command.Name)
+				return
+			}
 		}
 	}
 }
 
-func CommandClaimRewards(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func CommandClaimRewards(s discordapi.Session, i *discordgo.InteractionCreate) {
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
@@ -85,39 +87,35 @@ func CommandClaimRewards(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	})
 
 	userID := i.Member.User.ID
-	accountTitle := i.ApplicationCommandData().Options[0].StringValue()
+	accountId := i.ApplicationCommandData().Options[0].IntValue()
 
 	var account models.Account
-	result := database.DB.Where("title = ? AND user_id = ?", accountTitle, userID).First(&account)
+	result := database.DB.Where("id = ? AND user_id = ?", accountId, userID).First(&account)
 	if result.Error != nil {
 		logger.Log.WithError(result.Error).Error("Error retrieving account")
 		sendFollowUpMessage(s, i, "Error retrieving account information")
 		return
 	}
+	if account.IsExpiredCookie {
+		sendFollowUpMessage(s, i, fmt.Sprintf("The SSO cookie for account %s has expired. Update it with /updateaccount before claiming rewards.", account.Title))
+		return
+	}
 
-	rewardResults := claimRewards(account.SSOCookie)
-
-	message := fmt.Sprintf("Reward claim results for %s:\n%s", account.Title, strings.Join(rewardResults, "\n"))
-	sendFollowUpMessage(s, i, message)
-}
-
-func claimRewards(ssoCookie string) []string {
-	results := []string{}
-	codes := getRewardCodes()
-
-	for _, code := range codes {
-		result, err := services.ClaimSingleReward(ssoCookie, code) // Use the services package function
-		if err != nil {
-			results = append(results, fmt.Sprintf("Failed to claim reward for code %s: %v", code, err))
-		} else {
-			results = append(results, result)
-		}
+	reports, err := services.ClaimRewards(account)
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Error claiming rewards for account %s", account.Title)
+		sendFollowUpMessage(s, i, "Error claiming rewards")
+		return
 	}
 
-	return results
+	message := fmt.Sprintf("Reward claim results for %s:\n%s", account.Title, services.FormatClaimReport(reports))
+	if len(message) > 2000 {
+		message = message[:1997] + "..."
+	}
+	sendFollowUpMessage(s, i, message)
 }
 
-func sendFollowUpMessage(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+func sendFollowUpMessage(s discordapi.Session, i *discordgo.InteractionCreate, content string) {
 	_, err := s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
 		Content: content,
 		Flags:   discordgo.MessageFlagsEphemeral,
@@ -127,16 +125,6 @@ func sendFollowUpMessage(s *discordgo.Session, i *discordgo.InteractionCreate, c
 	}
 }
 
-func getRewardCodes() []string {
-	return []string{
-		"XBLX3HN7X77NAH7", // Blue MONSTER ENERGY OPERATOR
-		"KCZEKWKW6PCW3M3", // Double BattlePass XP
-		"APSBAXLFVZCXVKW", // Double BattlePass XP
-		"WK3SEKWCXSAE6EH", // Double BattlePass XP
-		"YPFYEMWXWCVKVLX", // Limited Weapon Charm
-	}
-}
-
 func getAllChoices(guildID string) []*discordgo.ApplicationCommandOptionChoice {
 	logger.Log.Info("Getting all choices for account select dropdown")
 	var accounts []models.Account
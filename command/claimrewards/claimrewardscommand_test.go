@@ -0,0 +1,75 @@
+package claimrewards
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"codstatusbot2.0/database/fakedb"
+	"codstatusbot2.0/discordapi/fake"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func claimInteraction(accountID int) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:        "interaction-1",
+		Type:      discordgo.InteractionApplicationCommand,
+		ChannelID: "channel-1",
+		Member:    &discordgo.Member{User: &discordgo.User{ID: "user-1"}},
+		Data: discordgo.ApplicationCommandInteractionData{
+			Name: "claimavailablerewards",
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{Name: "account", Type: discordgo.ApplicationCommandOptionInteger, Value: float64(accountID)},
+			},
+		},
+	}}
+}
+
+func TestCommandClaimRewards(t *testing.T) {
+	account := map[string]driver.Value{"id": int64(1), "user_id": "user-1", "title": "main", "is_expired_cookie": false}
+	expired := map[string]driver.Value{"id": int64(1), "user_id": "user-1", "title": "main", "is_expired_cookie": true}
+	tests := []struct {
+		name    string
+		account map[string]driver.Value // The stored account, nil if it does not exist.
+		claimed bool                    // Whether a code is published that was claimed for the account earlier.
+		want    string
+	}{
+		{name: "unknown account", want: "Error retrieving account information"},
+		{name: "expired cookie", account: expired,
+			want: "The SSO cookie for account main has expired. Update it with /updateaccount before claiming rewards."},
+		{name: "no codes", account: account, want: "Reward claim results for main:\nThere are no reward codes to claim right now."},
+		{name: "claimed earlier", account: account, claimed: true, want: "Reward claim results for main:\n- ABC (Double XP): already claimed earlier"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := fakedb.Use(t)
+			if test.account != nil {
+				db.Return("accounts", test.account)
+			}
+			if test.claimed {
+				db.Return("reward_codes", map[string]driver.Value{"id": int64(5), "code": "ABC", "description": "Double XP"})
+				db.Return("reward_claims", map[string]driver.Value{"account_id": int64(1), "reward_code_id": int64(5), "result": "claimed"})
+			}
+			session := fake.NewSession()
+
+			CommandClaimRewards(session, claimInteraction(1))
+
+			// Claiming can take longer than Discord waits for a response, so the command
+			// defers it and answers with a follow-up.
+			responses := session.Responses()
+			if len(responses) != 1 || responses[0].Response.Type != discordgo.InteractionResponseDeferredChannelMessageWithSource {
+				t.Fatalf("responded %+v, want one deferred response", responses)
+			}
+			if responses[0].Response.Data.Flags&discordgo.MessageFlagsEphemeral == 0 {
+				t.Error("the response is not ephemeral")
+			}
+			followups := session.Followups()
+			if len(followups) != 1 {
+				t.Fatalf("sent %d follow-ups, want one", len(followups))
+			}
+			if got := followups[0].Params.Content; got != test.want {
+				t.Errorf("follow-up = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
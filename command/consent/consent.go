@@ -0,0 +1,105 @@
+package consent
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/services"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	AcceptPrefix  = "consent_accept"
+	DeclinePrefix = "consent_decline"
+)
+
+// RequireConsent reports whether the invoking user has accepted the current privacy
+// policy. If they have not, the policy is shown with Accept/Decline buttons and the
+// calling command should stop handling the interaction.
+func RequireConsent(s discordapi.Session, i *discordgo.InteractionCreate) bool {
+	userID := interactionUserID(i)
+	if services.HasConsented(userID) {
+		return true
+	}
+	logger.Log.WithField("user_id", userID).Info("Prompting user to accept privacy policy")
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "Before the bot can store an SSO cookie for you, please read and accept the privacy policy.",
+			Embeds: []*discordgo.MessageEmbed{
+				{
+					Title:       "Privacy Policy",
+					Description: services.PrivacyPolicyText,
+					Color:       0xffff00,
+				},
+			},
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							Label:    "Accept",
+							Style:    discordgo.SuccessButton,
+							CustomID: fmt.Sprintf("%s:%d", AcceptPrefix, services.PrivacyPolicyVersion),
+						},
+						discordgo.Button{
+							Label:    "Decline",
+							Style:    discordgo.DangerButton,
+							CustomID: DeclinePrefix,
+						},
+					},
+				},
+			},
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		logger.Log.WithError(err).Error("Error sending privacy policy prompt")
+	}
+	return false
+}
+
+func HandleAccept(s discordapi.Session, i *discordgo.InteractionCreate) {
+	userID := interactionUserID(i)
+	customID := i.MessageComponentData().CustomID
+	version, err := strconv.Atoi(strings.TrimPrefix(customID, AcceptPrefix+":"))
+	if err != nil || version != services.PrivacyPolicyVersion {
+		updateMessage(s, i, "The privacy policy has changed since this prompt was shown. Please run the command again to review the current version.")
+		return
+	}
+
+	if err := services.RecordConsent(userID); err != nil {
+		updateMessage(s, i, "Error recording your consent, please try again later.")
+		return
+	}
+	logger.Log.WithField("user_id", userID).Info("User accepted privacy policy")
+	updateMessage(s, i, "Thank you, your consent has been recorded. Please run the command again to continue.")
+}
+
+func HandleDecline(s discordapi.Session, i *discordgo.InteractionCreate) {
+	logger.Log.WithField("user_id", interactionUserID(i)).Info("User declined privacy policy")
+	updateMessage(s, i, "You declined the privacy policy. The bot cannot monitor accounts without storing an SSO cookie, so nothing was saved.")
+}
+
+func updateMessage(s discordapi.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Embeds:     []*discordgo.MessageEmbed{},
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		logger.Log.WithError(err).Error("Error updating privacy policy prompt")
+	}
+}
+
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil {
+		return i.Member.User.ID
+	}
+	return i.User.ID
+}
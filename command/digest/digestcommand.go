@@ -0,0 +1,129 @@
+package digest
+
+import (
+	"fmt"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/services"
+	"github.com/bwmarrin/discordgo"
+)
+
+func RegisterCommand(s discordapi.Session, guildID string) {
+	commands := []*discordgo.ApplicationCommand{
+		{
+			Name:        "digest",
+			Description: "Choose how often you get a summary of all your accounts",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "schedule",
+					Description: "How often to send the digest, leave empty to see your current schedule",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "Daily", Value: services.DigestDaily},
+						{Name: "Weekly", Value: services.DigestWeekly},
+						{Name: "Off", Value: services.DigestOff},
+					},
+				},
+			},
+		},
+	}
+
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	var existingCommand *discordgo.ApplicationCommand
+	for _, command := range existingCommands {
+		if command.Name == "digest" {
+			existingCommand = command
+			break
+		}
+	}
+
+	newCommand := commands[0]
+
+	if existingCommand != nil {
+		logger.Log.Info("Updating digest command")
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error updating digest command")
+			return
+		}
+	} else {
+		logger.Log.Info("Creating digest command")
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error creating digest command")
+			return
+		}
+	}
+}
+
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	for _, command := range commands {
+		if command.Name == "digest" {
+			logger.Log.Infof("Deleting command %s", command.Name)
+			err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
+			if err != nil {
+				logger.Log.WithError(err).Errorf("Error deleting command %s", command.Name)
+				return
+			}
+		}
+	}
+}
+
+func CommandDigest(s discordapi.Session, i *discordgo.InteractionCreate) {
+	userID := interactionUserID(i)
+	settings := services.GetUserSettings(userID)
+
+	options := i.ApplicationCommandData().Options
+	if len(options) > 0 {
+		settings.DigestSchedule = options[0].StringValue()
+		// Restart the schedule so the first digest arrives one period from now.
+		settings.NextDigestAt = 0
+		if err := database.DB.Save(&settings).Error; err != nil {
+			logger.Log.WithError(err).Errorf("Error saving digest schedule for user %s", userID)
+			respond(s, i, "Error saving your digest schedule")
+			return
+		}
+		logger.Log.WithField("user", userID).Infof("Digest schedule set to %s", settings.DigestSchedule)
+	}
+
+	schedule := services.DigestSchedule(settings)
+	if schedule == services.DigestOff {
+		respond(s, i, "Digests are off. You will still be notified when the status of an account changes.")
+		return
+	}
+	message := fmt.Sprintf("You get a %s digest of all your accounts.", schedule)
+	if settings.NextDigestAt > 0 {
+		message += fmt.Sprintf(" The next one is due <t:%d:R>.", settings.NextDigestAt)
+	}
+	respond(s, i, message)
+}
+
+func respond(s discordapi.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil {
+		return i.Member.User.ID
+	}
+	return i.User.ID
+}
@@ -0,0 +1,161 @@
+package headerprofile
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+	"codstatusbot2.0/services"
+	"github.com/bwmarrin/discordgo"
+	"gorm.io/gorm"
+)
+
+// defaultProfile is the choice that removes an account's profile, so it goes back to
+// the configured default or rotation.
+const defaultProfile = "default"
+
+func RegisterCommand(s discordapi.Session, guildID string) {
+	choices := []*discordgo.ApplicationCommandOptionChoice{{Name: defaultProfile, Value: defaultProfile}}
+	for _, name := range services.HeaderProfileNames() {
+		if len(choices) == 25 {
+			break
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: name, Value: name})
+	}
+	commands := []*discordgo.ApplicationCommand{
+		{
+			Name:        "headerprofile",
+			Description: "Send an account's requests with a fixed header profile (bot owners only)",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "account_id",
+					Description: "The ID of the account",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "profile",
+					Description: "The header profile, or default for the configured default or rotation",
+					Required:    true,
+					Choices:     choices,
+				},
+			},
+		},
+	}
+
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	var existingCommand *discordgo.ApplicationCommand
+	for _, command := range existingCommands {
+		if command.Name == "headerprofile" {
+			existingCommand = command
+			break
+		}
+	}
+
+	newCommand := commands[0]
+
+	if existingCommand != nil {
+		logger.Log.Info("Updating headerprofile command")
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error updating headerprofile command")
+			return
+		}
+	} else {
+		logger.Log.Info("Creating headerprofile command")
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error creating headerprofile command")
+			return
+		}
+	}
+}
+
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	for _, command := range commands {
+		if command.Name == "headerprofile" {
+			logger.Log.Infof("Deleting command %s", command.Name)
+			err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
+			if err != nil {
+				logger.Log.WithError(err).Errorf("Error deleting command %s", command.Name)
+				return
+			}
+		}
+	}
+}
+
+func CommandHeaderProfile(s discordapi.Session, i *discordgo.InteractionCreate) {
+	userID := interactionUserID(i)
+	if !services.IsBotOwner(userID) {
+		respond(s, i, "Only bot owners can assign header profiles.")
+		return
+	}
+
+	options := make(map[string]*discordgo.ApplicationCommandInteractionDataOption)
+	for _, option := range i.ApplicationCommandData().Options {
+		options[option.Name] = option
+	}
+	name := ""
+	if profile := options["profile"].StringValue(); !strings.EqualFold(profile, defaultProfile) {
+		var err error
+		if name, err = services.HeaderProfileName(profile); err != nil {
+			respond(s, i, fmt.Sprintf("Could not assign the profile: %v", err))
+			return
+		}
+	}
+
+	var account models.Account
+	if err := database.DB.First(&account, options["account_id"].IntValue()).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respond(s, i, "Account does not exist")
+			return
+		}
+		logger.Log.WithError(err).Error("Error fetching account")
+		respond(s, i, "Error assigning the header profile")
+		return
+	}
+	if err := database.DB.Model(&account).Update("header_profile", name).Error; err != nil {
+		logger.Log.WithError(err).Errorf("Error assigning a header profile to account %s", account.Title)
+		respond(s, i, "Error assigning the header profile")
+		return
+	}
+	logger.Log.WithField("user", userID).Infof("Assigned header profile %q to account %d", name, account.ID)
+	if name == "" {
+		respond(s, i, fmt.Sprintf("Account %s uses the default header profile again.", account.Title))
+		return
+	}
+	respond(s, i, fmt.Sprintf("Account %s now uses the %s header profile.", account.Title, name))
+}
+
+func respond(s discordapi.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil {
+		return i.Member.User.ID
+	}
+	return i.User.ID
+}
@@ -1,12 +1,13 @@
 package help
 
 import (
+	"codstatusbot2.0/discordapi"
 	"codstatusbot2.0/logger"
 
 	"github.com/bwmarrin/discordgo"
 )
 
-func RegisterCommand(s *discordgo.Session, guildID string) {
+func RegisterCommand(s discordapi.Session, guildID string) {
 	commands := []*discordgo.ApplicationCommand{
 		{
 			Name:        "help",
@@ -14,7 +15,7 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 		},
 	}
 
-	existingCommands, err := s.ApplicationCommands(s.State.User.ID, guildID)
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error getting application commands")
 		return
@@ -32,14 +33,14 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 
 	if existingCommand != nil {
 		logger.Log.Info("Updating help command")
-		_, err = s.ApplicationCommandEdit(s.State.User.ID, guildID, existingCommand.ID, newCommand)
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
 		if err != nil {
 			logger.Log.WithError(err).Error("Error updating help command")
 			return
 		}
 	} else {
 		logger.Log.Info("Creating help command")
-		_, err = s.ApplicationCommandCreate(s.State.User.ID, guildID, newCommand)
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
 		if err != nil {
 			logger.Log.WithError(err).Error("Error creating help command")
 			return
@@ -47,8 +48,8 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 	}
 }
 
-func UnregisterCommand(s *discordgo.Session, guildID string) {
-	commands, err := s.ApplicationCommands(s.State.User.ID, guildID)
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error getting application commands")
 		return
@@ -56,7 +57,7 @@ func UnregisterCommand(s *discordgo.Session, guildID string) {
 
 	for _, command := range commands {
 		logger.Log.Infof("Deleting command %s", command.Name)
-		err := s.ApplicationCommandDelete(s.State.User.ID, guildID, command.ID)
+		err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
 		if err != nil {
 
 			logger.Log.WithError(err).Errorf("Error deleting command %s", command.Name)
@@ -66,7 +67,7 @@ func UnregisterCommand(s *discordgo.Session, guildID string) {
 	}
 }
 
-func CommandHelp(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func CommandHelp(s discordapi.Session, i *discordgo.InteractionCreate) {
 	logger.Log.Info("Received help command")
 	helpGuide := "CODStatusBot Help Guide\n\n" +
 		"To add your Call of Duty account to the bot, you'll need to obtain your SSO (Single Sign-On) cookie. Follow these steps:\n\n" +
@@ -0,0 +1,302 @@
+package listaccounts
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+	"codstatusbot2.0/services"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	PagePrefix   = "listaccounts_page"
+	FilterPrefix = "listaccounts_filter"
+	pageSize     = 5
+	filterAll    = "all"
+)
+
+var filters = []struct {
+	value string
+	label string
+}{
+	{filterAll, "All accounts"},
+	{string(models.StatusGood), "Good"},
+	{string(models.StatusShadowban), "Shadowbanned"},
+	{string(models.StatusPermaban), "Permanently banned"},
+	{string(models.StatusInvalidCookie), "Invalid cookie"},
+	{string(models.StatusUnknown), "Not checked yet"},
+}
+
+func RegisterCommand(s discordapi.Session, guildID string) {
+	commands := []*discordgo.ApplicationCommand{
+		{
+			Name:        "listaccounts",
+			Description: "Show all of your accounts in this server",
+		},
+	}
+
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	var existingCommand *discordgo.ApplicationCommand
+	for _, command := range existingCommands {
+		if command.Name == "listaccounts" {
+			existingCommand = command
+			break
+		}
+	}
+
+	newCommand := commands[0]
+
+	if existingCommand != nil {
+		logger.Log.Info("Updating listaccounts command")
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error updating listaccounts command")
+			return
+		}
+	} else {
+		logger.Log.Info("Creating listaccounts command")
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error creating listaccounts command")
+			return
+		}
+	}
+}
+
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	for _, command := range commands {
+		if command.Name == "listaccounts" {
+			logger.Log.Infof("Deleting command %s", command.Name)
+			err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
+			if err != nil {
+				logger.Log.WithError(err).Errorf("Error deleting command %s", command.Name)
+				return
+			}
+		}
+	}
+}
+
+func CommandListAccounts(s discordapi.Session, i *discordgo.InteractionCreate) {
+	data := buildDashboard(i.Member.User.ID, i.GuildID, filterAll, 0)
+	data.Flags = discordgo.MessageFlagsEphemeral
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: data,
+	})
+	if err != nil {
+		logger.Log.WithError(err).Error("Error responding to listaccounts command")
+	}
+}
+
+// HandlePage handles the Previous/Next buttons, whose custom IDs carry the filter and
+// the page to show as "listaccounts_page:<filter>:<page>".
+func HandlePage(s discordapi.Session, i *discordgo.InteractionCreate) {
+	parts := strings.Split(i.MessageComponentData().CustomID, ":")
+	if len(parts) != 3 {
+		logger.Log.WithField("component", i.MessageComponentData().CustomID).Error("Malformed listaccounts page button")
+		return
+	}
+	page, _ := strconv.Atoi(parts[2])
+	updateDashboard(s, i, parts[1], page)
+}
+
+func HandleFilter(s discordapi.Session, i *discordgo.InteractionCreate) {
+	filter := filterAll
+	if values := i.MessageComponentData().Values; len(values) > 0 {
+		filter = values[0]
+	}
+	updateDashboard(s, i, filter, 0)
+}
+
+func updateDashboard(s discordapi.Session, i *discordgo.InteractionCreate, filter string, page int) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: buildDashboard(i.Member.User.ID, i.GuildID, filter, page),
+	})
+	if err != nil {
+		logger.Log.WithError(err).Error("Error updating account dashboard")
+	}
+}
+
+func buildDashboard(userID, guildID, filter string, page int) *discordgo.InteractionResponseData {
+	query := database.DB.Where("user_id = ? AND guild_id = ?", userID, guildID)
+	switch filter {
+	case filterAll:
+	case string(models.StatusInvalidCookie):
+		query = query.Where("is_expired_cookie = ? OR last_status = ?", true, filter)
+	default:
+		query = query.Where("last_status = ? AND is_expired_cookie = ?", filter, false)
+	}
+
+	var accounts []models.Account
+	if err := query.Order("title").Find(&accounts).Error; err != nil {
+		logger.Log.WithError(err).Error("Error fetching accounts for dashboard")
+		return &discordgo.InteractionResponseData{Content: "Error fetching your accounts"}
+	}
+
+	pages := (len(accounts) + pageSize - 1) / pageSize
+	if pages == 0 {
+		pages = 1
+	}
+	if page < 0 {
+		page = 0
+	}
+	if page >= pages {
+		page = pages - 1
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:  "Your Accounts",
+		Color:  0x00ff00,
+		Footer: &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Page %d of %d - %d account(s) - %s", page+1, pages, len(accounts), filterLabel(filter))},
+	}
+	if len(accounts) == 0 {
+		embed.Description = "No accounts match this filter. Use /addaccount to start monitoring an account."
+	}
+
+	settings := services.GetGuildSettings(guildID)
+	end := (page + 1) * pageSize
+	if end > len(accounts) {
+		end = len(accounts)
+	}
+	for _, account := range accounts[page*pageSize : end] {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("%s - %s", account.Title, account.LastStatus),
+			Value: describeAccount(account, settings),
+		})
+	}
+
+	return &discordgo.InteractionResponseData{
+		Embeds: []*discordgo.MessageEmbed{embed},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Previous",
+						Style:    discordgo.SecondaryButton,
+						CustomID: fmt.Sprintf("%s:%s:%d", PagePrefix, filter, page-1),
+						Disabled: page == 0,
+					},
+					discordgo.Button{
+						Label:    "Next",
+						Style:    discordgo.SecondaryButton,
+						CustomID: fmt.Sprintf("%s:%s:%d", PagePrefix, filter, page+1),
+						Disabled: page >= pages-1,
+					},
+				},
+			},
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.SelectMenu{
+						CustomID:    FilterPrefix,
+						Placeholder: "Filter by status",
+						Options:     filterOptions(filter),
+					},
+				},
+			},
+		},
+	}
+}
+
+func describeAccount(account models.Account, settings models.GuildSettings) string {
+	lines := []string{
+		fmt.Sprintf("**Status:** %s since %s", account.LastStatus, relativeTime(statusSince(account))),
+		fmt.Sprintf("**Last check:** %s", relativeTime(account.LastCheck)),
+		fmt.Sprintf("**Cookie:** %s", cookieHealth(account)),
+		fmt.Sprintf("**Notifications:** %s", notificationTarget(account, settings)),
+	}
+	if profile, ok := services.GetAccountProfile(account.ID); ok {
+		lines = append(lines, fmt.Sprintf("**Linked:** %s", services.LinkedAccounts(profile)))
+	}
+	if bans := services.ActiveGameBans(account.ID); len(bans) > 0 {
+		lines = append(lines, fmt.Sprintf("**Game bans:**\n%s", services.GameBanSummary(bans)))
+	}
+	now := time.Now().Unix()
+	if account.PausedUntil > now {
+		lines = append(lines, fmt.Sprintf("**Paused** %s", services.FormatUntil(account.PausedUntil)))
+	}
+	if account.MutedUntil > now {
+		muted := fmt.Sprintf("**Muted** %s", services.FormatUntil(account.MutedUntil))
+		if account.MuteBanAlerts {
+			muted += ", including ban alerts"
+		}
+		lines = append(lines, muted)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// statusSince returns when the account entered its current status, using the most
+// recent status change record and falling back to when the account was added.
+func statusSince(account models.Account) int64 {
+	var ban models.Ban
+	err := database.DB.Where("account_id = ? AND status = ?", account.ID, account.LastStatus).Order("created_at desc").First(&ban).Error
+	if err != nil {
+		return account.CreatedAt.Unix()
+	}
+	return ban.CreatedAt.Unix()
+}
+
+func cookieHealth(account models.Account) string {
+	if account.IsExpiredCookie {
+		return "Expired, use /updateaccount"
+	}
+	if account.CookieExpiresAt == 0 {
+		return "Valid"
+	}
+	return "Valid, " + services.CookieExpiryText(account.CookieExpiresAt, time.Now())
+}
+
+func notificationTarget(account models.Account, settings models.GuildSettings) string {
+	if account.NotificationType == "dm" {
+		return "Direct message"
+	}
+	if settings.AlertsChannelID != "" {
+		return fmt.Sprintf("<#%s>", settings.AlertsChannelID)
+	}
+	return fmt.Sprintf("<#%s>", account.ChannelID)
+}
+
+func relativeTime(timestamp int64) string {
+	if timestamp == 0 {
+		return "never"
+	}
+	return fmt.Sprintf("<t:%d:R>", timestamp)
+}
+
+func filterOptions(selected string) []discordgo.SelectMenuOption {
+	options := make([]discordgo.SelectMenuOption, len(filters))
+	for i, filter := range filters {
+		options[i] = discordgo.SelectMenuOption{
+			Label:   filter.label,
+			Value:   filter.value,
+			Default: filter.value == selected,
+		}
+	}
+	return options
+}
+
+func filterLabel(value string) string {
+	for _, filter := range filters {
+		if filter.value == value {
+			return filter.label
+		}
+	}
+	return value
+}
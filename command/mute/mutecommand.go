@@ -0,0 +1,164 @@
+package mute
+
+import (
+	"fmt"
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+	"codstatusbot2.0/services"
+	"github.com/bwmarrin/discordgo"
+)
+
+func RegisterCommand(s discordapi.Session, guildID string) {
+	commands := []*discordgo.ApplicationCommand{
+		{
+			Name:        "mute",
+			Description: "Leave an account out of your digest and stop its cookie reminders",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionType(discordgo.InteractionApplicationCommandAutocomplete),
+					Name:        "account",
+					Description: "The title of the account",
+					Required:    true,
+					Choices:     getAllChoices(guildID),
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "duration",
+					Description: "How long to mute for, e.g. 12h, 3d, 2w, indefinitely, or off to unmute",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "ban_alerts",
+					Description: "Also mute alerts when the account's ban status changes (default: false)",
+					Required:    false,
+				},
+			},
+		},
+	}
+
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	var existingCommand *discordgo.ApplicationCommand
+	for _, command := range existingCommands {
+		if command.Name == "mute" {
+			existingCommand = command
+			break
+		}
+	}
+
+	newCommand := commands[0]
+
+	if existingCommand != nil {
+		logger.Log.Info("Updating mute command")
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error updating mute command")
+			return
+		}
+	} else {
+		logger.Log.Info("Creating mute command")
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error creating mute command")
+			return
+		}
+	}
+}
+
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	for _, command := range commands {
+		if command.Name == "mute" {
+			logger.Log.Infof("Deleting command %s", command.Name)
+			err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
+			if err != nil {
+				logger.Log.WithError(err).Errorf("Error deleting command %s", command.Name)
+				return
+			}
+		}
+	}
+}
+
+func CommandMute(s discordapi.Session, i *discordgo.InteractionCreate) {
+	userID := i.Member.User.ID
+	guildID := i.GuildID
+	options := i.ApplicationCommandData().Options
+	accountId := options[0].IntValue()
+	duration := options[1].StringValue()
+	muteBanAlerts := false
+	if len(options) > 2 {
+		muteBanAlerts = options[2].BoolValue()
+	}
+
+	until, err := services.ParseUntil(duration, time.Now())
+	if err != nil {
+		respond(s, i, "Invalid duration. Use a number followed by m, h, d or w (for example 12h or 3d), indefinitely, or off.")
+		return
+	}
+
+	var account models.Account
+	result := database.DB.Where("user_id = ? AND id = ? AND guild_id = ?", userID, accountId, guildID).First(&account)
+	if result.Error != nil {
+		logger.Log.WithError(result.Error).Error("Error retrieving account")
+		respond(s, i, "Account does not exist")
+		return
+	}
+
+	err = database.DB.Model(&account).Updates(map[string]interface{}{
+		"muted_until":     until,
+		"mute_ban_alerts": muteBanAlerts && until != 0,
+	}).Error
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Error muting account %s", account.Title)
+		respond(s, i, "Error muting the account")
+		return
+	}
+
+	if until == 0 {
+		respond(s, i, fmt.Sprintf("Notifications for account %s are no longer muted.", account.Title))
+		return
+	}
+	banAlerts := "Ban alerts will still be sent."
+	if muteBanAlerts {
+		banAlerts = "Ban alerts are muted too."
+	}
+	respond(s, i, fmt.Sprintf("Account %s is left out of your digest and its cookie reminders are muted %s. The account is still checked. %s", account.Title, services.FormatUntil(until), banAlerts))
+}
+
+func respond(s discordapi.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func getAllChoices(guildID string) []*discordgo.ApplicationCommandOptionChoice {
+	logger.Log.Info("Getting all choices for account select dropdown")
+	var accounts []models.Account
+	database.DB.Where("guild_id = ?", guildID).Find(&accounts)
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, len(accounts))
+	for i, account := range accounts {
+		choices[i] = &discordgo.ApplicationCommandOptionChoice{
+			Name:  account.Title,
+			Value: account.ID,
+		}
+	}
+	return choices
+}
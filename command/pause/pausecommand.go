@@ -0,0 +1,146 @@
+package pause
+
+import (
+	"fmt"
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+	"codstatusbot2.0/services"
+	"github.com/bwmarrin/discordgo"
+)
+
+func RegisterCommand(s discordapi.Session, guildID string) {
+	commands := []*discordgo.ApplicationCommand{
+		{
+			Name:        "pause",
+			Description: "Stop checking an account for a while",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionType(discordgo.InteractionApplicationCommandAutocomplete),
+					Name:        "account",
+					Description: "The title of the account",
+					Required:    true,
+					Choices:     getAllChoices(guildID),
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "duration",
+					Description: "How long to pause for, e.g. 12h, 3d, 2w, indefinitely, or off to resume",
+					Required:    true,
+				},
+			},
+		},
+	}
+
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	var existingCommand *discordgo.ApplicationCommand
+	for _, command := range existingCommands {
+		if command.Name == "pause" {
+			existingCommand = command
+			break
+		}
+	}
+
+	newCommand := commands[0]
+
+	if existingCommand != nil {
+		logger.Log.Info("Updating pause command")
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error updating pause command")
+			return
+		}
+	} else {
+		logger.Log.Info("Creating pause command")
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error creating pause command")
+			return
+		}
+	}
+}
+
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	for _, command := range commands {
+		if command.Name == "pause" {
+			logger.Log.Infof("Deleting command %s", command.Name)
+			err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
+			if err != nil {
+				logger.Log.WithError(err).Errorf("Error deleting command %s", command.Name)
+				return
+			}
+		}
+	}
+}
+
+func CommandPause(s discordapi.Session, i *discordgo.InteractionCreate) {
+	userID := i.Member.User.ID
+	guildID := i.GuildID
+	accountId := i.ApplicationCommandData().Options[0].IntValue()
+	duration := i.ApplicationCommandData().Options[1].StringValue()
+
+	until, err := services.ParseUntil(duration, time.Now())
+	if err != nil {
+		respond(s, i, "Invalid duration. Use a number followed by m, h, d or w (for example 12h or 3d), indefinitely, or off.")
+		return
+	}
+
+	var account models.Account
+	result := database.DB.Where("user_id = ? AND id = ? AND guild_id = ?", userID, accountId, guildID).First(&account)
+	if result.Error != nil {
+		logger.Log.WithError(result.Error).Error("Error retrieving account")
+		respond(s, i, "Account does not exist")
+		return
+	}
+
+	if err := database.DB.Model(&account).Update("paused_until", until).Error; err != nil {
+		logger.Log.WithError(err).Errorf("Error pausing account %s", account.Title)
+		respond(s, i, "Error pausing the account")
+		return
+	}
+
+	if until == 0 {
+		services.EnqueuePriorityCheck(account.ID)
+		respond(s, i, fmt.Sprintf("Account %s is no longer paused and will be checked again.", account.Title))
+		return
+	}
+	respond(s, i, fmt.Sprintf("Account %s is paused %s. It will not be checked and no notifications will be sent.", account.Title, services.FormatUntil(until)))
+}
+
+func respond(s discordapi.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func getAllChoices(guildID string) []*discordgo.ApplicationCommandOptionChoice {
+	logger.Log.Info("Getting all choices for account select dropdown")
+	var accounts []models.Account
+	database.DB.Where("guild_id = ?", guildID).Find(&accounts)
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, len(accounts))
+	for i, account := range accounts {
+		choices[i] = &discordgo.ApplicationCommandOptionChoice{
+			Name:  account.Title,
+			Value: account.ID,
+		}
+	}
+	return choices
+}
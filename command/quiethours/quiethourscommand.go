@@ -0,0 +1,205 @@
+package quiethours
+
+import (
+	"fmt"
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+	"codstatusbot2.0/services"
+	"github.com/bwmarrin/discordgo"
+)
+
+func RegisterCommand(s discordapi.Session, guildID string) {
+	minHour := float64(0)
+	commands := []*discordgo.ApplicationCommand{
+		{
+			Name:        "quiethours",
+			Description: "Hold non-critical notifications overnight, run without options to view your settings",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "enabled",
+					Description: "Turn quiet hours on or off",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "timezone",
+					Description: "Your timezone, e.g. Europe/London or America/New_York",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "start",
+					Description: "The hour quiet hours start (0-23)",
+					MinValue:    &minHour,
+					MaxValue:    23,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "end",
+					Description: "The hour quiet hours end (0-23)",
+					MinValue:    &minHour,
+					MaxValue:    23,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "permaban_alerts",
+					Description: "Send permanent ban alerts during quiet hours",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        "shadowban_alerts",
+					Description: "Send shadowban alerts during quiet hours",
+				},
+			},
+		},
+	}
+
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	var existingCommand *discordgo.ApplicationCommand
+	for _, command := range existingCommands {
+		if command.Name == "quiethours" {
+			existingCommand = command
+			break
+		}
+	}
+
+	newCommand := commands[0]
+
+	if existingCommand != nil {
+		logger.Log.Info("Updating quiethours command")
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error updating quiethours command")
+			return
+		}
+	} else {
+		logger.Log.Info("Creating quiethours command")
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error creating quiethours command")
+			return
+		}
+	}
+}
+
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	for _, command := range commands {
+		if command.Name == "quiethours" {
+			logger.Log.Infof("Deleting command %s", command.Name)
+			err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
+			if err != nil {
+				logger.Log.WithError(err).Errorf("Error deleting command %s", command.Name)
+				return
+			}
+		}
+	}
+}
+
+func CommandQuietHours(s discordapi.Session, i *discordgo.InteractionCreate) {
+	userID := interactionUserID(i)
+	settings := services.GetUserSettings(userID)
+
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		respondSettings(s, i, settings)
+		return
+	}
+
+	for _, option := range options {
+		switch option.Name {
+		case "enabled":
+			settings.QuietHoursEnabled = option.BoolValue()
+		case "timezone":
+			location, err := time.LoadLocation(option.StringValue())
+			if err != nil {
+				respond(s, i, "Unknown timezone. Use a name from the tz database such as Europe/London or America/New_York.")
+				return
+			}
+			settings.Timezone = location.String()
+		case "start":
+			settings.QuietStart = int(option.IntValue())
+		case "end":
+			settings.QuietEnd = int(option.IntValue())
+		case "permaban_alerts":
+			settings.PermabanBreaksQuiet = option.BoolValue()
+		case "shadowban_alerts":
+			settings.ShadowbanBreaksQuiet = option.BoolValue()
+		}
+	}
+
+	if err := database.DB.Save(&settings).Error; err != nil {
+		logger.Log.WithError(err).Errorf("Error saving quiet hours for user %s", userID)
+		respond(s, i, "Error saving your quiet hours")
+		return
+	}
+	logger.Log.WithField("user", userID).Info("Updated quiet hours")
+	respondSettings(s, i, settings)
+}
+
+func respondSettings(s discordapi.Session, i *discordgo.InteractionCreate, settings models.UserSettings) {
+	status := "Off"
+	if settings.QuietHoursEnabled {
+		status = fmt.Sprintf("%02d:00 to %02d:00", settings.QuietStart, settings.QuietEnd)
+		if settings.QuietStart == settings.QuietEnd {
+			status += " (start and end are the same, so nothing is held)"
+		}
+	}
+	permabanAlerts := "Held until quiet hours end"
+	if settings.PermabanBreaksQuiet {
+		permabanAlerts = "Sent immediately"
+	}
+	shadowbanAlerts := "Held until quiet hours end"
+	if settings.ShadowbanBreaksQuiet {
+		shadowbanAlerts = "Sent immediately"
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Quiet Hours",
+		Description: "During quiet hours digests, cookie reminders and status changes are held and sent when quiet hours end.",
+		Color:       0x00ff00,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Quiet hours", Value: status},
+			{Name: "Timezone", Value: settings.Timezone},
+			{Name: "Permanent ban alerts", Value: permabanAlerts},
+			{Name: "Shadowban alerts", Value: shadowbanAlerts},
+		},
+	}
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func respond(s discordapi.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil {
+		return i.Member.User.ID
+	}
+	return i.User.ID
+}
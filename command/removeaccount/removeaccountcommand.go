@@ -1,13 +1,18 @@
 package removeaccount
 
 import (
+	"errors"
+
 	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
 	"codstatusbot2.0/logger"
 	"codstatusbot2.0/models"
+	"codstatusbot2.0/services"
 	"github.com/bwmarrin/discordgo"
+	"gorm.io/gorm"
 )
 
-func RegisterCommand(s *discordgo.Session, guildID string) {
+func RegisterCommand(s discordapi.Session, guildID string) {
 	commands := []*discordgo.ApplicationCommand{
 		{
 			Name:        "removeaccount",
@@ -23,7 +28,7 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 			},
 		},
 	}
-	existingCommands, err := s.ApplicationCommands(s.State.User.ID, guildID)
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error getting application commands from guild")
 		return
@@ -38,80 +43,52 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 	newCommand := commands[0]
 	if existingCommand != nil {
 		logger.Log.Info("Updating the removeaccount command")
-		_, err = s.ApplicationCommandEdit(s.State.User.ID, guildID, existingCommand.ID, newCommand)
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
 		if err != nil {
 			logger.Log.WithError(err).Error("Error updating the removeaccount command")
 			return
 		}
 	} else {
 		logger.Log.Info("Adding the removeaccount command")
-		_, err = s.ApplicationCommandCreate(s.State.User.ID, guildID, newCommand)
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
 		if err != nil {
 			logger.Log.WithError(err).Error("Error adding the removeaccount command")
 			return
 		}
 	}
 }
-func UnregisterCommand(s *discordgo.Session, guildID string) {
-	commands, err := s.ApplicationCommands(s.State.User.ID, guildID)
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error getting application commands for unregistering removeaccount command")
 		return
 	}
 	for _, command := range commands {
 		logger.Log.Infof("Deleting command %s", command.Name)
-		err := s.ApplicationCommandDelete(s.State.User.ID, guildID, command.ID)
+		err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
 		if err != nil {
 			logger.Log.WithError(err).Errorf("Error unregistering the command %s", command.Name)
 			continue
 		}
 	}
 }
-func CommandRemoveAccount(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func CommandRemoveAccount(s discordapi.Session, i *discordgo.InteractionCreate) {
 	userID := i.Member.User.ID
 	guildID := i.GuildID
 	accountId := i.ApplicationCommandData().Options[0].IntValue()
-	tx := database.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		} else {
-			err := tx.Exec("SET FOREIGN_KEY_CHECKS=1;").Error
-			if err != nil {
-				logger.Log.WithError(err).Error("Error re-enabling foreign key constraints")
-				tx.Rollback()
-				return
-			}
-			tx.Commit()
-		}
-	}()
-	err := tx.Exec("SET FOREIGN_KEY_CHECKS=0;").Error
+	err := DeleteAccount(userID, guildID, uint(accountId))
 	if err != nil {
-		logger.Log.WithError(err).Error("Error disabling foreign key constraints")
-		tx.Rollback()
-		return
-	}
-	var account models.Account
-	result := tx.Where("user_id = ? AND id = ? AND guild_id = ?", userID, accountId, guildID).First(&account)
-	if result.Error != nil {
+		content := "Error removing account"
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			content = "Account does not exist"
+		}
 		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
 			Data: &discordgo.InteractionResponseData{
-				Content: "Account does not exist",
+				Content: content,
 				Flags:   discordgo.MessageFlagsEphemeral,
 			},
 		})
-		tx.Rollback()
-		return
-	}
-	if err := tx.Unscoped().Where("account_id = ?", account.ID).Delete(&models.Ban{}).Error; err != nil {
-		logger.Log.WithError(err).Error("Error deleting associated bans for account", account.ID)
-		tx.Rollback()
-		return
-	}
-	if err := tx.Unscoped().Where("id = ?", account.ID).Delete(&models.Account{}).Error; err != nil {
-		logger.Log.WithError(err).Error("Error deleting account from database", account.ID)
-		tx.Rollback()
 		return
 	}
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
@@ -123,6 +100,16 @@ func CommandRemoveAccount(s *discordgo.Session, i *discordgo.InteractionCreate)
 	})
 	UpdateAccountChoices(s, guildID)
 }
+
+// DeleteAccount permanently removes an account owned by userID in guildID together
+// with everything stored about it.
+func DeleteAccount(userID, guildID string, accountId uint) error {
+	var account models.Account
+	if err := database.DB.Where("user_id = ? AND id = ? AND guild_id = ?", userID, accountId, guildID).First(&account).Error; err != nil {
+		return err
+	}
+	return services.PurgeAccount(account)
+}
 func getAllChoices(guildID string) []*discordgo.ApplicationCommandOptionChoice {
 	logger.Log.Info("Getting all choices for account select dropdown")
 	var accounts []models.Account
@@ -136,8 +123,8 @@ func getAllChoices(guildID string) []*discordgo.ApplicationCommandOptionChoice {
 	}
 	return choices
 }
-func UpdateAccountChoices(s *discordgo.Session, guildID string) {
-	commands, err := s.ApplicationCommands(s.State.User.ID, guildID)
+func UpdateAccountChoices(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error getting application command choices")
 		return
@@ -145,7 +132,9 @@ func UpdateAccountChoices(s *discordgo.Session, guildID string) {
 	logger.Log.Info("Updating account choices for commands")
 	newChoices := getAllChoices(guildID)
 	for _, command := range commands {
-		if command.Name == "removeaccount" || command.Name == "accountlogs" || command.Name == "updateaccount" || command.Name == "accountage" {
+		if command.Name == "removeaccount" || command.Name == "accountlogs" || command.Name == "updateaccount" || command.Name == "accountage" || command.Name == "setcheckinterval" ||
+			command.Name == "pause" || command.Name == "mute" || command.Name == "claimavailablerewards" ||
+			command.Name == "autoclaim" || command.Name == "appeal" {
 			newCommand := &discordgo.ApplicationCommand{
 				Name:        command.Name,
 				Description: command.Description,
@@ -156,7 +145,7 @@ func UpdateAccountChoices(s *discordgo.Session, guildID string) {
 					option.Choices = newChoices
 				}
 			}
-			_, err := s.ApplicationCommandEdit(s.State.User.ID, guildID, command.ID, newCommand)
+			_, err := s.ApplicationCommandEdit(s.BotUserID(), guildID, command.ID, newCommand)
 			if err != nil {
 				logger.Log.WithError(err).Errorf("Error updating command %s", command.Name)
 				return
@@ -0,0 +1,183 @@
+package rewardcodes
+
+import (
+	"fmt"
+	"strings"
+
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/services"
+	"github.com/bwmarrin/discordgo"
+)
+
+func RegisterCommand(s discordapi.Session, guildID string) {
+	commands := []*discordgo.ApplicationCommand{
+		{
+			Name:        "rewardcodes",
+			Description: "Manage the reward codes offered by /claimavailablerewards (bot owners only)",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "list",
+					Description: "Show the published reward codes",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "add",
+					Description: "Publish a new reward code",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "code",
+							Description: "The reward code",
+							Required:    true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "description",
+							Description: "What the code unlocks",
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "remove",
+					Description: "Withdraw a reward code",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "code",
+							Description: "The reward code",
+							Required:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	var existingCommand *discordgo.ApplicationCommand
+	for _, command := range existingCommands {
+		if command.Name == "rewardcodes" {
+			existingCommand = command
+			break
+		}
+	}
+
+	newCommand := commands[0]
+
+	if existingCommand != nil {
+		logger.Log.Info("Updating rewardcodes command")
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error updating rewardcodes command")
+			return
+		}
+	} else {
+		logger.Log.Info("Creating rewardcodes command")
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error creating rewardcodes command")
+			return
+		}
+	}
+}
+
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	for _, command := range commands {
+		if command.Name == "rewardcodes" {
+			logger.Log.Infof("Deleting command %s", command.Name)
+			err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
+			if err != nil {
+				logger.Log.WithError(err).Errorf("Error deleting command %s", command.Name)
+				return
+			}
+		}
+	}
+}
+
+func CommandRewardCodes(s discordapi.Session, i *discordgo.InteractionCreate) {
+	userID := interactionUserID(i)
+	if !services.IsBotOwner(userID) {
+		respond(s, i, "Only bot owners can manage reward codes.")
+		return
+	}
+
+	subcommand := i.ApplicationCommandData().Options[0]
+	options := make(map[string]*discordgo.ApplicationCommandInteractionDataOption)
+	for _, option := range subcommand.Options {
+		options[option.Name] = option
+	}
+
+	switch subcommand.Name {
+	case "list":
+		codes, err := services.ListRewardCodes()
+		if err != nil {
+			logger.Log.WithError(err).Error("Error listing reward codes")
+			respond(s, i, "Error listing reward codes")
+			return
+		}
+		if len(codes) == 0 {
+			respond(s, i, "No reward codes are published.")
+			return
+		}
+		lines := make([]string, len(codes))
+		for n, code := range codes {
+			lines[n] = fmt.Sprintf("- `%s` %s (added <t:%d:R>)", code.Code, code.Description, code.CreatedAt.Unix())
+		}
+		respond(s, i, strings.Join(lines, "\n"))
+	case "add":
+		description := ""
+		if option, ok := options["description"]; ok {
+			description = option.StringValue()
+		}
+		code, err := services.AddRewardCode(options["code"].StringValue(), description, userID)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error adding reward code")
+			respond(s, i, fmt.Sprintf("Could not add the code: %v", err))
+			return
+		}
+		logger.Log.WithField("user", userID).Infof("Published reward code %s", code.Code)
+		respond(s, i, fmt.Sprintf("Reward code `%s` published. It will be claimed shortly for every account with auto-claim on.", code.Code))
+	case "remove":
+		code := services.NormalizeRewardCode(options["code"].StringValue())
+		if err := services.RemoveRewardCode(code); err != nil {
+			logger.Log.WithError(err).Errorf("Error removing reward code %s", code)
+			respond(s, i, "That reward code does not exist.")
+			return
+		}
+		logger.Log.WithField("user", userID).Infof("Withdrew reward code %s", code)
+		respond(s, i, fmt.Sprintf("Reward code `%s` removed.", code))
+	default:
+		respond(s, i, "Unknown subcommand")
+	}
+}
+
+func respond(s discordapi.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil {
+		return i.Member.User.ID
+	}
+	return i.User.ID
+}
@@ -0,0 +1,335 @@
+package serverconfig
+
+import (
+	"fmt"
+	"strings"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+	"codstatusbot2.0/services"
+	"github.com/bwmarrin/discordgo"
+)
+
+func RegisterCommand(s discordapi.Session, guildID string) {
+	adminPermission := int64(discordgo.PermissionManageServer)
+	dmPermission := false
+	minLimit := float64(0)
+	commands := []*discordgo.ApplicationCommand{
+		{
+			Name:                     "serverconfig",
+			Description:              "Configure how the bot behaves in this server",
+			DefaultMemberPermissions: &adminPermission,
+			DMPermission:             &dmPermission,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "view",
+					Description: "Show the current server settings",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "alerts_channel",
+					Description: "Send channel notifications to a dedicated channel, leave empty to reset",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionChannel,
+							Name:         "channel",
+							Description:  "The channel to send notifications to",
+							ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "ping_role",
+					Description: "Mention a role when a ban is detected, leave empty to reset",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionRole,
+							Name:        "role",
+							Description: "The role to mention",
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "allowed_roles",
+					Description: "Restrict the bot to members with certain roles",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "action",
+							Description: "Add or remove a role, or clear the list to allow everyone",
+							Required:    true,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "Add", Value: "add"},
+								{Name: "Remove", Value: "remove"},
+								{Name: "Clear", Value: "clear"},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionRole,
+							Name:        "role",
+							Description: "The role to add or remove",
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "account_limit",
+					Description: "Limit how many accounts each member can add, 0 for no limit",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "limit",
+							Description: "The maximum number of accounts per member",
+							Required:    true,
+							MinValue:    &minLimit,
+							MaxValue:    1000,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "ban_wave_announcements",
+					Description: "Announce confirmed ban waves in the alerts channel",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Name:        "enabled",
+							Description: "Turn the announcements on or off",
+							Required:    true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "notification_mode",
+					Description: "Where notifications for newly added accounts go by default",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "mode",
+							Description: "The default notification mode",
+							Required:    true,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "Channel", Value: "channel"},
+								{Name: "DM", Value: "dm"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	var existingCommand *discordgo.ApplicationCommand
+	for _, command := range existingCommands {
+		if command.Name == "serverconfig" {
+			existingCommand = command
+			break
+		}
+	}
+
+	newCommand := commands[0]
+
+	if existingCommand != nil {
+		logger.Log.Info("Updating serverconfig command")
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error updating serverconfig command")
+			return
+		}
+	} else {
+		logger.Log.Info("Creating serverconfig command")
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error creating serverconfig command")
+			return
+		}
+	}
+}
+
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	for _, command := range commands {
+		if command.Name == "serverconfig" {
+			logger.Log.Infof("Deleting command %s", command.Name)
+			err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
+			if err != nil {
+				logger.Log.WithError(err).Errorf("Error deleting command %s", command.Name)
+				return
+			}
+		}
+	}
+}
+
+func CommandServerConfig(s discordapi.Session, i *discordgo.InteractionCreate) {
+	if i.Member == nil || i.Member.Permissions&discordgo.PermissionManageServer == 0 {
+		respond(s, i, "You need the Manage Server permission to change the server settings.")
+		return
+	}
+
+	guildID := i.GuildID
+	subcommand := i.ApplicationCommandData().Options[0]
+	options := make(map[string]*discordgo.ApplicationCommandInteractionDataOption)
+	for _, option := range subcommand.Options {
+		options[option.Name] = option
+	}
+
+	settings := services.GetGuildSettings(guildID)
+	var message string
+	switch subcommand.Name {
+	case "view":
+		respondSettings(s, i, settings)
+		return
+	case "alerts_channel":
+		settings.AlertsChannelID = ""
+		message = "Notifications will be sent to the channel each account was added from."
+		if option, ok := options["channel"]; ok {
+			settings.AlertsChannelID = option.ChannelValue(nil).ID
+			message = fmt.Sprintf("Notifications will be sent to <#%s>.", settings.AlertsChannelID)
+		}
+	case "ping_role":
+		settings.PingRoleID = ""
+		message = "No role will be mentioned when a ban is detected."
+		if option, ok := options["role"]; ok {
+			settings.PingRoleID = option.RoleValue(nil, guildID).ID
+			message = fmt.Sprintf("<@&%s> will be mentioned when a ban is detected.", settings.PingRoleID)
+		}
+	case "allowed_roles":
+		roles := services.AllowedRoles(settings)
+		action := options["action"].StringValue()
+		if action == "clear" {
+			roles = nil
+		} else {
+			option, ok := options["role"]
+			if !ok {
+				respond(s, i, "Please choose a role to "+action+".")
+				return
+			}
+			roles = updateRoles(roles, option.RoleValue(nil, guildID).ID, action == "add")
+		}
+		settings.AllowedRoleIDs = strings.Join(roles, ",")
+		message = "Everyone can use the bot in this server."
+		if len(roles) > 0 {
+			message = "Only members with these roles can use the bot: " + mentionRoles(roles)
+		}
+	case "account_limit":
+		settings.AccountLimit = int(options["limit"].IntValue())
+		message = "Members can add any number of accounts."
+		if settings.AccountLimit > 0 {
+			message = fmt.Sprintf("Members can add up to %d accounts.", settings.AccountLimit)
+		}
+	case "ban_wave_announcements":
+		settings.BanWaveAnnouncements = options["enabled"].BoolValue()
+		message = "Ban waves will not be announced in this server."
+		if settings.BanWaveAnnouncements {
+			message = "Confirmed ban waves will be announced in the alerts channel."
+			if settings.AlertsChannelID == "" {
+				message += " Set one with `/serverconfig alerts_channel`, announcements are only sent there."
+			}
+		}
+	case "notification_mode":
+		settings.DefaultNotificationType = options["mode"].StringValue()
+		message = fmt.Sprintf("Newly added accounts will send notifications by %s.", settings.DefaultNotificationType)
+	default:
+		respond(s, i, "Unknown setting")
+		return
+	}
+
+	if err := database.DB.Save(&settings).Error; err != nil {
+		logger.Log.WithError(err).Errorf("Error saving settings for guild %s", guildID)
+		respond(s, i, "Error saving the server settings")
+		return
+	}
+	logger.Log.WithField("guild", guildID).Infof("Updated server setting %s", subcommand.Name)
+	respond(s, i, message)
+}
+
+func respondSettings(s discordapi.Session, i *discordgo.InteractionCreate, settings models.GuildSettings) {
+	alertsChannel := "Channel each account was added from"
+	if settings.AlertsChannelID != "" {
+		alertsChannel = fmt.Sprintf("<#%s>", settings.AlertsChannelID)
+	}
+	pingRole := "None"
+	if settings.PingRoleID != "" {
+		pingRole = fmt.Sprintf("<@&%s>", settings.PingRoleID)
+	}
+	allowedRoles := "Everyone"
+	if roles := services.AllowedRoles(settings); len(roles) > 0 {
+		allowedRoles = mentionRoles(roles)
+	}
+	banWaves := "Off"
+	if settings.BanWaveAnnouncements {
+		banWaves = "On"
+	}
+	accountLimit := "No limit"
+	if settings.AccountLimit > 0 {
+		accountLimit = fmt.Sprintf("%d per member", settings.AccountLimit)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: "Server Settings",
+		Color: 0x00ff00,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Alerts channel", Value: alertsChannel},
+			{Name: "Ping role", Value: pingRole},
+			{Name: "Allowed roles", Value: allowedRoles},
+			{Name: "Account limit", Value: accountLimit},
+			{Name: "Default notification mode", Value: settings.DefaultNotificationType},
+			{Name: "Ban wave announcements", Value: banWaves},
+		},
+	}
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func updateRoles(roles []string, roleID string, add bool) []string {
+	updated := make([]string, 0, len(roles)+1)
+	for _, role := range roles {
+		if role != roleID {
+			updated = append(updated, role)
+		}
+	}
+	if add {
+		updated = append(updated, roleID)
+	}
+	return updated
+}
+
+func mentionRoles(roles []string) string {
+	mentions := make([]string, len(roles))
+	for i, role := range roles {
+		mentions[i] = fmt.Sprintf("<@&%s>", role)
+	}
+	return strings.Join(mentions, ", ")
+}
+
+func respond(s discordapi.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
@@ -0,0 +1,153 @@
+package setcheckinterval
+
+import (
+	"fmt"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+	"codstatusbot2.0/services"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	minInterval = 5
+	maxInterval = 24 * 60
+)
+
+func RegisterCommand(s discordapi.Session, guildID string) {
+	minValue := float64(0)
+	commands := []*discordgo.ApplicationCommand{
+		{
+			Name:        "setcheckinterval",
+			Description: "Set how often an account is checked",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionType(discordgo.InteractionApplicationCommandAutocomplete),
+					Name:        "account",
+					Description: "The title of the account",
+					Required:    true,
+					Choices:     getAllChoices(guildID),
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        "minutes",
+					Description: fmt.Sprintf("Minutes between checks (%d-%d), 0 to use the default", minInterval, maxInterval),
+					Required:    true,
+					MinValue:    &minValue,
+					MaxValue:    maxInterval,
+				},
+			},
+		},
+	}
+
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	var existingCommand *discordgo.ApplicationCommand
+	for _, command := range existingCommands {
+		if command.Name == "setcheckinterval" {
+			existingCommand = command
+			break
+		}
+	}
+
+	newCommand := commands[0]
+
+	if existingCommand != nil {
+		logger.Log.Info("Updating setcheckinterval command")
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error updating setcheckinterval command")
+			return
+		}
+	} else {
+		logger.Log.Info("Creating setcheckinterval command")
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
+		if err != nil {
+			logger.Log.WithError(err).Error("Error creating setcheckinterval command")
+			return
+		}
+	}
+}
+
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Error getting application commands")
+		return
+	}
+
+	for _, command := range commands {
+		if command.Name == "setcheckinterval" {
+			logger.Log.Infof("Deleting command %s", command.Name)
+			err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
+			if err != nil {
+				logger.Log.WithError(err).Errorf("Error deleting command %s", command.Name)
+				return
+			}
+		}
+	}
+}
+
+func CommandSetCheckInterval(s discordapi.Session, i *discordgo.InteractionCreate) {
+	userID := i.Member.User.ID
+	guildID := i.GuildID
+	accountId := i.ApplicationCommandData().Options[0].IntValue()
+	minutes := i.ApplicationCommandData().Options[1].IntValue()
+
+	if minutes != 0 && (minutes < minInterval || minutes > maxInterval) {
+		respond(s, i, fmt.Sprintf("The interval must be between %d and %d minutes, or 0 to use the default.", minInterval, maxInterval))
+		return
+	}
+
+	var account models.Account
+	result := database.DB.Where("user_id = ? AND id = ? AND guild_id = ?", userID, accountId, guildID).First(&account)
+	if result.Error != nil {
+		logger.Log.WithError(result.Error).Error("Error retrieving account")
+		respond(s, i, "Account does not exist")
+		return
+	}
+
+	account.CheckInterval = minutes
+	if err := database.DB.Model(&account).Update("check_interval", minutes).Error; err != nil {
+		logger.Log.WithError(err).Errorf("Error saving check interval for account %s", account.Title)
+		respond(s, i, "Error saving the check interval")
+		return
+	}
+	services.EnqueuePriorityCheck(account.ID)
+
+	if minutes == 0 {
+		respond(s, i, fmt.Sprintf("Account %s will be checked at the default interval.", account.Title))
+		return
+	}
+	respond(s, i, fmt.Sprintf("Account %s will be checked every %d minutes.", account.Title, minutes))
+}
+
+func respond(s discordapi.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func getAllChoices(guildID string) []*discordgo.ApplicationCommandOptionChoice {
+	logger.Log.Info("Getting all choices for account select dropdown")
+	var accounts []models.Account
+	database.DB.Where("guild_id = ?", guildID).Find(&accounts)
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, len(accounts))
+	for i, account := range accounts {
+		choices[i] = &discordgo.ApplicationCommandOptionChoice{
+			Name:  account.Title,
+			Value: account.ID,
+		}
+	}
+	return choices
+}
@@ -2,12 +2,13 @@ package setpreference
 
 import (
 	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
 	"codstatusbot2.0/logger"
 	"codstatusbot2.0/models"
 	"github.com/bwmarrin/discordgo"
 )
 
-func RegisterCommand(s *discordgo.Session, guildID string) {
+func RegisterCommand(s discordapi.Session, guildID string) {
 	commands := []*discordgo.ApplicationCommand{
 		{
 			Name:        "setpreference",
@@ -33,7 +34,7 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 		},
 	}
 
-	existingCommands, err := s.ApplicationCommands(s.State.User.ID, guildID)
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error getting application commands")
 		return
@@ -51,14 +52,14 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 
 	if existingCommand != nil {
 		logger.Log.Info("Updating setpreference command")
-		_, err = s.ApplicationCommandEdit(s.State.User.ID, guildID, existingCommand.ID, newCommand)
+		_, err = s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
 		if err != nil {
 			logger.Log.WithError(err).Error("Error updating setpreference command")
 			return
 		}
 	} else {
 		logger.Log.Info("Creating setpreference command")
-		_, err = s.ApplicationCommandCreate(s.State.User.ID, guildID, newCommand)
+		_, err = s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
 		if err != nil {
 			logger.Log.WithError(err).Error("Error creating setpreference command")
 			return
@@ -66,8 +67,8 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 	}
 }
 
-func UnregisterCommand(s *discordgo.Session, guildID string) {
-	commands, err := s.ApplicationCommands(s.State.User.ID, guildID)
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error getting application commands")
 		return
@@ -76,7 +77,7 @@ func UnregisterCommand(s *discordgo.Session, guildID string) {
 	for _, command := range commands {
 		if command.Name == "setpreference" {
 			logger.Log.Infof("Deleting command %s", command.Name)
-			err := s.ApplicationCommandDelete(s.State.User.ID, guildID, command.ID)
+			err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
 			if err != nil {
 				logger.Log.WithError(err).Errorf("Error deleting command %s", command.Name)
 				return
@@ -85,7 +86,7 @@ func UnregisterCommand(s *discordgo.Session, guildID string) {
 	}
 }
 
-func CommandSetPreference(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func CommandSetPreference(s discordapi.Session, i *discordgo.InteractionCreate) {
 	userID := i.Member.User.ID
 	guildID := i.GuildID
 	preferenceType := i.ApplicationCommandData().Options[0].StringValue()
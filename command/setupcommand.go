@@ -1,20 +1,77 @@
 package command
 
 import (
+	"codstatusbot2.0/command/accountactions"
 	"codstatusbot2.0/command/accountage"
 	"codstatusbot2.0/command/accountlogs"
 	"codstatusbot2.0/command/addaccount"
+	"codstatusbot2.0/command/appeal"
+	"codstatusbot2.0/command/autoclaim"
+	"codstatusbot2.0/command/claimrewards"
+	"codstatusbot2.0/command/consent"
+	"codstatusbot2.0/command/digest"
+	"codstatusbot2.0/command/headerprofile"
 	"codstatusbot2.0/command/help"
+	"codstatusbot2.0/command/listaccounts"
+	"codstatusbot2.0/command/mute"
+	"codstatusbot2.0/command/pause"
+	"codstatusbot2.0/command/quiethours"
 	"codstatusbot2.0/command/removeaccount"
+	"codstatusbot2.0/command/rewardcodes"
+	"codstatusbot2.0/command/serverconfig"
+	"codstatusbot2.0/command/setcheckinterval"
 	"codstatusbot2.0/command/updateaccount"
+	"codstatusbot2.0/discordapi"
 	"codstatusbot2.0/logger"
+	"codstatusbot2.0/services"
 
 	"github.com/bwmarrin/discordgo"
 )
 
-var Handlers = map[string]func(*discordgo.Session, *discordgo.InteractionCreate){}
+var Handlers = map[string]func(discordapi.Session, *discordgo.InteractionCreate){}
+
+// ComponentHandlers are keyed by the part of a component's custom ID before the first colon.
+var ComponentHandlers = map[string]func(discordapi.Session, *discordgo.InteractionCreate){
+	consent.AcceptPrefix:       consent.HandleAccept,
+	consent.DeclinePrefix:      consent.HandleDecline,
+	listaccounts.PagePrefix:    listaccounts.HandlePage,
+	listaccounts.FilterPrefix:  listaccounts.HandleFilter,
+	services.ActionRecheck:     accountactions.HandleRecheck,
+	services.ActionUpdate:      accountactions.HandleUpdate,
+	services.ActionHistory:     accountactions.HandleHistory,
+	services.ActionSnooze:      accountactions.HandleSnooze,
+	services.ActionStop:        accountactions.HandleStop,
+	services.ActionStopConfirm: accountactions.HandleStopConfirm,
+	services.ActionStopCancel:  accountactions.HandleStopCancel,
+}
+
+// ModalHandlers are keyed by the part of a modal's custom ID before the first colon.
+var ModalHandlers = map[string]func(discordapi.Session, *discordgo.InteractionCreate){
+	services.ActionUpdateModal: accountactions.HandleUpdateModal,
+}
+
+// unrestrictedCommands can be used by every member even when a server limits the bot
+// to certain roles.
+var unrestrictedCommands = map[string]bool{
+	"help":          true,
+	"serverconfig":  true,
+	"rewardcodes":   true,
+	"headerprofile": true,
+}
 
-func RegisterCommands(s *discordgo.Session, guildID string) {
+// Allowed reports whether the member invoking the interaction may use the command,
+// based on the roles allowed by the server's settings.
+func Allowed(i *discordgo.InteractionCreate) bool {
+	if i.Member == nil || i.GuildID == "" {
+		return true
+	}
+	if i.Type == discordgo.InteractionApplicationCommand && unrestrictedCommands[i.ApplicationCommandData().Name] {
+		return true
+	}
+	return services.MemberAllowed(i.GuildID, i.Member.Roles)
+}
+
+func RegisterCommands(s discordapi.Session, guildID string) {
 	logger.Log.Info("Registering commands by command handler")
 
 	removeaccount.RegisterCommand(s, guildID)
@@ -43,18 +100,60 @@ func RegisterCommands(s *discordgo.Session, guildID string) {
 	Handlers["addaccount"] = addaccount.CommandAddAccount
 	logger.Log.Info("Registering addaccount command")
 
-	/*
-		claimrewards.RegisterCommand(s, guildID)
-		Handlers["claimavailablerewards"] = claimrewards.CommandClaimRewards
-		logger.Log.Info("Registering claimavailablerewards command")
-	*/
+	listaccounts.RegisterCommand(s, guildID)
+	Handlers["listaccounts"] = listaccounts.CommandListAccounts
+	logger.Log.Info("Registering listaccounts command")
+
+	pause.RegisterCommand(s, guildID)
+	Handlers["pause"] = pause.CommandPause
+	logger.Log.Info("Registering pause command")
+
+	mute.RegisterCommand(s, guildID)
+	Handlers["mute"] = mute.CommandMute
+	logger.Log.Info("Registering mute command")
+
+	setcheckinterval.RegisterCommand(s, guildID)
+	Handlers["setcheckinterval"] = setcheckinterval.CommandSetCheckInterval
+	logger.Log.Info("Registering setcheckinterval command")
+
+	quiethours.RegisterCommand(s, guildID)
+	Handlers["quiethours"] = quiethours.CommandQuietHours
+	logger.Log.Info("Registering quiethours command")
+
+	digest.RegisterCommand(s, guildID)
+	Handlers["digest"] = digest.CommandDigest
+	logger.Log.Info("Registering digest command")
+
+	claimrewards.RegisterCommand(s, guildID)
+	Handlers["claimavailablerewards"] = claimrewards.CommandClaimRewards
+	logger.Log.Info("Registering claimavailablerewards command")
+
+	rewardcodes.RegisterCommand(s, guildID)
+	Handlers["rewardcodes"] = rewardcodes.CommandRewardCodes
+	logger.Log.Info("Registering rewardcodes command")
+
+	headerprofile.RegisterCommand(s, guildID)
+	Handlers["headerprofile"] = headerprofile.CommandHeaderProfile
+	logger.Log.Info("Registering headerprofile command")
+
+	autoclaim.RegisterCommand(s, guildID)
+	Handlers["autoclaim"] = autoclaim.CommandAutoClaim
+	logger.Log.Info("Registering autoclaim command")
+
+	appeal.RegisterCommand(s, guildID)
+	Handlers["appeal"] = appeal.CommandAppeal
+	logger.Log.Info("Registering appeal command")
+
+	serverconfig.RegisterCommand(s, guildID)
+	Handlers["serverconfig"] = serverconfig.CommandServerConfig
+	logger.Log.Info("Registering serverconfig command")
 
 	help.RegisterCommand(s, guildID)
 	Handlers["help"] = help.CommandHelp
 	logger.Log.Info("Registering help command")
 }
 
-func UnregisterCommands(s *discordgo.Session, guildID string) {
+func UnregisterCommands(s discordapi.Session, guildID string) {
 	logger.Log.Info("Unregistering commands by command handler")
 
 	addaccount.UnregisterCommand(s, guildID)
@@ -77,10 +176,42 @@ func UnregisterCommands(s *discordgo.Session, guildID string) {
 	accountage.UnregisterCommand(s, guildID)
 	logger.Log.Info("Unregistering accountage command")
 
-	/*
-		claimrewards.UnregisterCommand(s, guildID)
-		logger.Log.Info("Unregistering claimavailablerewards command")
-	*/
+	listaccounts.UnregisterCommand(s, guildID)
+	logger.Log.Info("Unregistering listaccounts command")
+
+	pause.UnregisterCommand(s, guildID)
+	logger.Log.Info("Unregistering pause command")
+
+	mute.UnregisterCommand(s, guildID)
+	logger.Log.Info("Unregistering mute command")
+
+	setcheckinterval.UnregisterCommand(s, guildID)
+	logger.Log.Info("Unregistering setcheckinterval command")
+
+	quiethours.UnregisterCommand(s, guildID)
+	logger.Log.Info("Unregistering quiethours command")
+
+	digest.UnregisterCommand(s, guildID)
+	logger.Log.Info("Unregistering digest command")
+
+	claimrewards.UnregisterCommand(s, guildID)
+	logger.Log.Info("Unregistering claimavailablerewards command")
+
+	rewardcodes.UnregisterCommand(s, guildID)
+	logger.Log.Info("Unregistering rewardcodes command")
+
+	headerprofile.UnregisterCommand(s, guildID)
+	logger.Log.Info("Unregistering headerprofile command")
+
+	autoclaim.UnregisterCommand(s, guildID)
+	logger.Log.Info("Unregistering autoclaim command")
+
+	appeal.UnregisterCommand(s, guildID)
+	logger.Log.Info("Unregistering appeal command")
+
+	serverconfig.UnregisterCommand(s, guildID)
+	logger.Log.Info("Unregistering serverconfig command")
+
 	help.UnregisterCommand(s, guildID)
 	logger.Log.Info("Unregistering help command")
 
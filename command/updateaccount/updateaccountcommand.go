@@ -1,7 +1,12 @@
 package updateaccount
 
 import (
+	"fmt"
+	"time"
+
+	"codstatusbot2.0/command/consent"
 	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
 	"codstatusbot2.0/logger"
 	"codstatusbot2.0/models"
 	"codstatusbot2.0/services"
@@ -22,7 +27,7 @@ func getAllChoices(guildID string) []*discordgo.ApplicationCommandOptionChoice {
 	return choices
 }
 
-func RegisterCommand(s *discordgo.Session, guildID string) {
+func RegisterCommand(s discordapi.Session, guildID string) {
 	commands := []*discordgo.ApplicationCommand{
 		{
 			Name:        "updateaccount",
@@ -45,7 +50,7 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 		},
 	}
 
-	existingCommands, err := s.ApplicationCommands(s.State.User.ID, guildID)
+	existingCommands, err := s.ApplicationCommands(s.BotUserID(), guildID)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error getting application commands")
 		return
@@ -63,14 +68,14 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 
 	if existingCommand != nil {
 		logger.Log.Info("Updating updateaccount command")
-		_, err := s.ApplicationCommandEdit(s.State.User.ID, guildID, existingCommand.ID, newCommand)
+		_, err := s.ApplicationCommandEdit(s.BotUserID(), guildID, existingCommand.ID, newCommand)
 		if err != nil {
 			logger.Log.WithError(err).Error("Error updating updateaccount command")
 			return
 		}
 	} else {
 		logger.Log.Info("Creating updateaccount command")
-		_, err := s.ApplicationCommandCreate(s.State.User.ID, guildID, newCommand)
+		_, err := s.ApplicationCommandCreate(s.BotUserID(), guildID, newCommand)
 		if err != nil {
 			logger.Log.WithError(err).Error("Error creating updateaccount command")
 			return
@@ -78,8 +83,8 @@ func RegisterCommand(s *discordgo.Session, guildID string) {
 	}
 }
 
-func UnregisterCommand(s *discordgo.Session, guildID string) {
-	commands, err := s.ApplicationCommands(s.State.User.ID, guildID)
+func UnregisterCommand(s discordapi.Session, guildID string) {
+	commands, err := s.ApplicationCommands(s.BotUserID(), guildID)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error getting application commands")
 		return
@@ -87,7 +92,7 @@ func UnregisterCommand(s *discordgo.Session, guildID string) {
 
 	for _, command := range commands {
 		logger.Log.Infof("Deleting command %s", command.Name)
-		err := s.ApplicationCommandDelete(s.State.User.ID, guildID, command.ID)
+		err := s.ApplicationCommandDelete(s.BotUserID(), guildID, command.ID)
 		if err != nil {
 			logger.Log.WithError(err).Errorf("Error deleting command %s ", command.Name)
 			return
@@ -95,7 +100,11 @@ func UnregisterCommand(s *discordgo.Session, guildID string) {
 	}
 }
 
-func CommandUpdateAccount(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func CommandUpdateAccount(s discordapi.Session, i *discordgo.InteractionCreate) {
+	if !consent.RequireConsent(s, i) {
+		return
+	}
+
 	userID := i.Member.User.ID
 	guildID := i.GuildID
 	accountId := i.ApplicationCommandData().Options[0].IntValue()
@@ -140,15 +149,25 @@ func CommandUpdateAccount(s *discordgo.Session, i *discordgo.InteractionCreate)
 	account.LastStatus = models.StatusUnknown
 	account.IsExpiredCookie = false
 	account.LastCookieNotification = 0
+	account.CookieExpiresAt = services.CookieExpiresAt(newSSOCookie)
+	account.CookieWarningLevel = 0
 
 	tx.Save(&account)
 	tx.Commit()
+	services.EnqueuePriorityCheck(account.ID)
 
 	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: "Account SSO cookie updated",
+			Content: "Account SSO cookie updated" + cookieExpiryNote(account.CookieExpiresAt),
 			Flags:   discordgo.MessageFlagsEphemeral,
 		},
 	})
 }
+
+func cookieExpiryNote(expiresAt int64) string {
+	if expiresAt == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", the new cookie %s", services.CookieExpiryText(expiresAt, time.Now()))
+}
@@ -1,10 +1,9 @@
 package database
 
 import (
+	"codstatusbot2.0/config"
 	"codstatusbot2.0/models"
-	"errors"
 	"fmt"
-	"os"
 
 	"codstatusbot2.0/logger"
 
@@ -14,23 +13,26 @@ import (
 
 var DB *gorm.DB
 
-func Databaselogin() error {
-	logger.Log.Info("Connecting to database...")
-	dbUser := os.Getenv("DB_USER")
-	dbPassword := os.Getenv("DB_PASSWORD")
-	dbHost := os.Getenv("DB_HOST")
-	dbPort := os.Getenv("DB_PORT")
-	dbName := os.Getenv("DB_NAME")
-	dbVar := os.Getenv("DB_VAR")
-
-	var err error
-
-	if dbUser == "" || dbPassword == "" || dbHost == "" || dbPort == "" || dbName == "" || dbVar == "" {
-		err = errors.New("one or more environment variables for database not set or missing")
-		logger.Log.WithError(err).WithField("Bot Startup ", "database variables ").Error()
+// Models lists every model the bot stores, parents before the tables that refer to them.
+var Models = []interface{}{
+	&models.Account{}, &models.Ban{}, &models.Consent{}, &models.Lock{}, &models.GuildSettings{},
+	&models.UserSettings{}, &models.Notification{}, &models.AccountProfile{},
+	&models.RewardCode{}, &models.RewardClaim{}, &models.GameBan{},
+	&models.BanWave{}, &models.AuditLog{},
+}
+
+// Databaselogin connects to the database and brings its schema up to date.
+func Databaselogin(cfg config.DatabaseConfig) error {
+	if err := Connect(cfg); err != nil {
 		return err
 	}
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s%s", dbUser, dbPassword, dbHost, dbPort, dbName, dbVar)
+	return Migrate()
+}
+
+// Connect opens the database connection without touching the schema.
+func Connect(cfg config.DatabaseConfig) error {
+	logger.Log.Info("Connecting to database...")
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s%s", cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name, cfg.Params)
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
 	if err != nil {
 		logger.Log.WithError(err).WithField("Bot Startup ", "Mysql Config ").Error()
@@ -38,8 +40,12 @@ func Databaselogin() error {
 	}
 
 	DB = db
+	return nil
+}
 
-	err = DB.AutoMigrate(&models.Account{}, &models.Ban{})
+// Migrate creates missing tables and columns for every model.
+func Migrate() error {
+	err := DB.AutoMigrate(Models...)
 	if err != nil {
 		logger.Log.WithError(err).WithField("Bot Startup ", "Database Models Problem ").Error()
 		return err
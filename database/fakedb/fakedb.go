@@ -0,0 +1,189 @@
+// Package fakedb provides a database/sql driver for tests that stands in for MySQL
+// behind database.DB. Queries find no rows unless rows were set up for their table,
+// and every other statement is recorded with its arguments.
+package fakedb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"codstatusbot2.0/database"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// Exec is a statement other than a query, with the arguments bound to it.
+type Exec struct {
+	Query string
+	Args  []driver.Value
+}
+
+// Set returns the value the statement assigns to column, e.g. in
+// "UPDATE `accounts` SET `is_expired_cookie`=?".
+func (e Exec) Set(column string) (driver.Value, bool) {
+	at := strings.Index(e.Query, "`"+column+"`=?")
+	if at < 0 {
+		return nil, false
+	}
+	i := strings.Count(e.Query[:at], "?")
+	if i >= len(e.Args) {
+		return nil, false
+	}
+	return e.Args[i], true
+}
+
+// DB reports one affected row for every statement, so code that reads optional
+// settings and writes its results can run without MySQL. The zero value is not
+// usable, use Use.
+type DB struct {
+	mu     sync.Mutex
+	execs  []Exec
+	tables map[string][]map[string]driver.Value // Rows returned by queries, by table.
+}
+
+var (
+	register sync.Once
+	open     sync.Map // Open DBs by data source name.
+)
+
+// Use points database.DB at a new DB for the duration of the test.
+func Use(t testing.TB) *DB {
+	t.Helper()
+	register.Do(func() { sql.Register("fakedb", fakeDriver{}) })
+	db := &DB{tables: make(map[string][]map[string]driver.Value)}
+	open.Store(t.Name(), db)
+	sqlDB, err := sql.Open("fakedb", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gormDB, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}),
+		&gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := database.DB
+	database.DB = gormDB
+	t.Cleanup(func() {
+		database.DB = previous
+		sqlDB.Close()
+		open.Delete(t.Name())
+	})
+	return db
+}
+
+// Return makes every query that selects from table return rows, which all need the
+// same columns.
+func (db *DB) Return(table string, rows ...map[string]driver.Value) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.tables[table] = rows
+}
+
+// Execs returns every statement other than a query sent so far.
+func (db *DB) Execs() []Exec {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return append([]Exec(nil), db.execs...)
+}
+
+func (db *DB) exec(query string, args []driver.Value) driver.Result {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.execs = append(db.execs, Exec{Query: query, Args: args})
+	return driver.RowsAffected(1)
+}
+
+func (db *DB) query(query string) driver.Rows {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for table, rows := range db.tables {
+		if !strings.Contains(query, "FROM `"+table+"`") || len(rows) == 0 {
+			continue
+		}
+		result := &fakeRows{}
+		for column := range rows[0] {
+			result.columns = append(result.columns, column)
+		}
+		sort.Strings(result.columns)
+		for _, row := range rows {
+			values := make([]driver.Value, len(result.columns))
+			for i, column := range result.columns {
+				values[i] = row[column]
+			}
+			result.rows = append(result.rows, values)
+		}
+		return result
+	}
+	return &fakeRows{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	db, _ := open.Load(name)
+	return fakeConn{db.(*DB)}, nil
+}
+
+type fakeConn struct{ db *DB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+func (c fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return c.db.exec(query, values), nil
+}
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.db.query(query), nil
+}
+
+type fakeStmt struct {
+	conn  fakeConn
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.db.exec(s.query, args), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.db.query(s.query), nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
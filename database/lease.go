@@ -0,0 +1,70 @@
+package database
+
+import (
+	"time"
+
+	"codstatusbot2.0/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// ClaimAccounts takes an expiring lease on every account in ids that is still due and
+// not already leased by another instance, and returns the accounts that were claimed.
+// Checking that the account is still due stops an instance that read the ids before
+// another instance checked and released the account from checking it again.
+func ClaimAccounts(ids []uint, owner string, ttl time.Duration) ([]models.Account, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	now := time.Now()
+	expires := now.Add(ttl).Unix()
+	err := DB.Model(&models.Account{}).
+		Where("id IN ? AND (lease_expires_at < ? OR lease_owner = ?)", ids, now.Unix(), owner).
+		Where("next_check_at <= ? AND paused_until <= ?", now.Unix(), now.Unix()).
+		Updates(map[string]interface{}{"lease_owner": owner, "lease_expires_at": expires}).Error
+	if err != nil {
+		return nil, err
+	}
+	var accounts []models.Account
+	err = DB.Where("id IN ? AND lease_owner = ? AND lease_expires_at = ?", ids, owner, expires).Order("id").Find(&accounts).Error
+	return accounts, err
+}
+
+// ReleaseAccount gives up the lease on an account if it is still held by owner.
+func ReleaseAccount(id uint, owner string) error {
+	return DB.Model(&models.Account{}).
+		Where("id = ? AND lease_owner = ?", id, owner).
+		Updates(map[string]interface{}{"lease_owner": "", "lease_expires_at": 0}).Error
+}
+
+// AcquireLock takes or renews the named lock for owner. It reports whether owner
+// holds the lock afterwards.
+func AcquireLock(name, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	expires := now.Add(ttl).UnixMilli()
+
+	lock := models.Lock{Name: name, Owner: owner, ExpiresAt: expires}
+	result := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&lock)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		err := DB.Model(&models.Lock{}).
+			Where("name = ? AND (owner = ? OR expires_at < ?)", name, owner, now.UnixMilli()).
+			Updates(map[string]interface{}{"owner": owner, "expires_at": expires}).Error
+		if err != nil {
+			return false, err
+		}
+	}
+
+	var current models.Lock
+	if err := DB.Where("name = ?", name).First(&current).Error; err != nil {
+		return false, err
+	}
+	return current.Owner == owner && current.ExpiresAt >= now.UnixMilli(), nil
+}
+
+// ReleaseLock gives up the named lock if it is held by owner.
+func ReleaseLock(name, owner string) error {
+	return DB.Where("name = ? AND owner = ?", name, owner).Delete(&models.Lock{}).Error
+}
@@ -0,0 +1,347 @@
+// Package fake provides an in-memory discordapi.Session that records everything the
+// bot sends and can be told to fail the way Discord does.
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"codstatusbot2.0/discordapi"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Message is a message sent to a channel or DM.
+type Message struct {
+	ID         string
+	ChannelID  string
+	Content    string
+	Embeds     []*discordgo.MessageEmbed
+	Components []discordgo.MessageComponent
+}
+
+// Response is a response to an interaction.
+type Response struct {
+	Interaction *discordgo.Interaction
+	Response    *discordgo.InteractionResponse
+}
+
+// Followup is a follow-up message sent after an interaction was responded to.
+type Followup struct {
+	Interaction *discordgo.Interaction
+	Params      *discordgo.WebhookParams
+}
+
+// Session records messages, interaction responses and registered commands instead of
+// sending them to Discord. The zero value is not usable, use NewSession.
+type Session struct {
+	UserID string // Returned by BotUserID.
+
+	mu        sync.Mutex
+	nextID    int
+	messages  []Message
+	responses []Response
+	followups []Followup
+	responded map[string]bool                            // Interaction IDs that have been responded to.
+	commands  map[string][]*discordgo.ApplicationCommand // Registered commands by guild ID.
+	forbidden map[string]bool                            // Channels the bot may not send to.
+	closedDMs map[string]bool                            // Users who do not accept DMs from the bot.
+	failures  map[string][]error                         // Errors to return from the next calls, by method name.
+}
+
+var _ discordapi.Session = (*Session)(nil)
+
+func NewSession() *Session {
+	return &Session{
+		UserID:    "bot",
+		responded: make(map[string]bool),
+		commands:  make(map[string][]*discordgo.ApplicationCommand),
+		forbidden: make(map[string]bool),
+		closedDMs: make(map[string]bool),
+		failures:  make(map[string][]error),
+	}
+}
+
+// DMChannelID returns the ID of the DM channel UserChannelCreate returns for userID.
+func DMChannelID(userID string) string {
+	return "dm-" + userID
+}
+
+// Forbid makes every message sent to channelID fail as if the bot had lost its
+// permissions there.
+func (s *Session) Forbid(channelID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forbidden[channelID] = true
+}
+
+// CloseDMs makes messages to userID's DM channel fail as if the user had turned off
+// DMs from server members.
+func (s *Session) CloseDMs(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closedDMs[userID] = true
+}
+
+// FailNext makes the next call to method, e.g. "InteractionRespond", return err. Calls
+// queue up, so failing twice makes the next two calls fail.
+func (s *Session) FailNext(method string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = append(s.failures[method], err)
+}
+
+// Messages returns every message sent so far, in order.
+func (s *Session) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// MessagesTo returns the messages sent to channelID. Use DMChannelID for DMs.
+func (s *Session) MessagesTo(channelID string) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var messages []Message
+	for _, message := range s.messages {
+		if message.ChannelID == channelID {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// Embeds returns the embeds of every message sent so far, in order.
+func (s *Session) Embeds() []*discordgo.MessageEmbed {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var embeds []*discordgo.MessageEmbed
+	for _, message := range s.messages {
+		embeds = append(embeds, message.Embeds...)
+	}
+	return embeds
+}
+
+// Responses returns every interaction response so far, in order.
+func (s *Session) Responses() []Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Response(nil), s.responses...)
+}
+
+// Followups returns every follow-up message so far, in order.
+func (s *Session) Followups() []Followup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Followup(nil), s.followups...)
+}
+
+// Commands returns the commands registered in guildID.
+func (s *Session) Commands(guildID string) []*discordgo.ApplicationCommand {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*discordgo.ApplicationCommand(nil), s.commands[guildID]...)
+}
+
+// Reset forgets everything recorded so far. Simulated failures are kept.
+func (s *Session) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+	s.responses = nil
+	s.followups = nil
+	s.responded = make(map[string]bool)
+	s.commands = make(map[string][]*discordgo.ApplicationCommand)
+}
+
+func (s *Session) BotUserID() string {
+	return s.UserID
+}
+
+// ChannelMessageSendEmbed records the embed through ChannelMessageSendComplex, which is
+// also the method name to pass to FailNext.
+func (s *Session) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{embed}}, options...)
+}
+
+func (s *Session) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ChannelMessageSendComplex"); err != nil {
+		return nil, err
+	}
+	if s.forbidden[channelID] {
+		return nil, RESTError(http.StatusForbidden, discordgo.ErrCodeMissingPermissions, "Missing Permissions")
+	}
+	for userID := range s.closedDMs {
+		if channelID == DMChannelID(userID) {
+			return nil, RESTError(http.StatusForbidden, discordgo.ErrCodeCannotSendMessagesToThisUser, "Cannot send messages to this user")
+		}
+	}
+	embeds := data.Embeds
+	if data.Embed != nil {
+		embeds = append([]*discordgo.MessageEmbed{data.Embed}, embeds...)
+	}
+	message := Message{
+		ID:         s.newID(),
+		ChannelID:  channelID,
+		Content:    data.Content,
+		Embeds:     embeds,
+		Components: data.Components,
+	}
+	s.messages = append(s.messages, message)
+	return &discordgo.Message{
+		ID:         message.ID,
+		ChannelID:  channelID,
+		Content:    message.Content,
+		Embeds:     message.Embeds,
+		Components: message.Components,
+	}, nil
+}
+
+func (s *Session) UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("UserChannelCreate"); err != nil {
+		return nil, err
+	}
+	return &discordgo.Channel{
+		ID:         DMChannelID(recipientID),
+		Type:       discordgo.ChannelTypeDM,
+		Recipients: []*discordgo.User{{ID: recipientID}},
+	}, nil
+}
+
+// InteractionRespond fails like Discord does when an interaction is responded to twice.
+func (s *Session) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("InteractionRespond"); err != nil {
+		return err
+	}
+	if s.responded[interaction.ID] {
+		return RESTError(http.StatusBadRequest, discordgo.ErrCodeInteractionHasAlreadyBeenAcknowledged, "Interaction has already been acknowledged.")
+	}
+	s.responded[interaction.ID] = true
+	s.responses = append(s.responses, Response{Interaction: interaction, Response: resp})
+	return nil
+}
+
+// FollowupMessageCreate fails like Discord does when the interaction has not been
+// responded to yet.
+func (s *Session) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("FollowupMessageCreate"); err != nil {
+		return nil, err
+	}
+	if !s.responded[interaction.ID] {
+		return nil, RESTError(http.StatusNotFound, discordgo.ErrCodeUnknownWebhook, "Unknown Webhook")
+	}
+	s.followups = append(s.followups, Followup{Interaction: interaction, Params: data})
+	return &discordgo.Message{
+		ID:         s.newID(),
+		ChannelID:  interaction.ChannelID,
+		Content:    data.Content,
+		Embeds:     data.Embeds,
+		Components: data.Components,
+	}, nil
+}
+
+func (s *Session) ApplicationCommands(appID, guildID string, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ApplicationCommands"); err != nil {
+		return nil, err
+	}
+	return append([]*discordgo.ApplicationCommand(nil), s.commands[guildID]...), nil
+}
+
+// ApplicationCommandCreate replaces a command with the same name, as Discord does.
+func (s *Session) ApplicationCommandCreate(appID, guildID string, cmd *discordgo.ApplicationCommand, options ...discordgo.RequestOption) (*discordgo.ApplicationCommand, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ApplicationCommandCreate"); err != nil {
+		return nil, err
+	}
+	created := *cmd
+	created.ApplicationID = appID
+	created.GuildID = guildID
+	for i, existing := range s.commands[guildID] {
+		if existing.Name == cmd.Name {
+			created.ID = existing.ID
+			s.commands[guildID][i] = &created
+			return &created, nil
+		}
+	}
+	created.ID = s.newID()
+	s.commands[guildID] = append(s.commands[guildID], &created)
+	return &created, nil
+}
+
+func (s *Session) ApplicationCommandEdit(appID, guildID, cmdID string, cmd *discordgo.ApplicationCommand, options ...discordgo.RequestOption) (*discordgo.ApplicationCommand, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ApplicationCommandEdit"); err != nil {
+		return nil, err
+	}
+	for i, existing := range s.commands[guildID] {
+		if existing.ID == cmdID {
+			updated := *cmd
+			updated.ID = cmdID
+			updated.ApplicationID = appID
+			updated.GuildID = guildID
+			s.commands[guildID][i] = &updated
+			return &updated, nil
+		}
+	}
+	return nil, RESTError(http.StatusNotFound, discordgo.ErrCodeUnknownApplicationCommand, "Unknown application command")
+}
+
+func (s *Session) ApplicationCommandDelete(appID, guildID, cmdID string, options ...discordgo.RequestOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure("ApplicationCommandDelete"); err != nil {
+		return err
+	}
+	for i, existing := range s.commands[guildID] {
+		if existing.ID == cmdID {
+			s.commands[guildID] = append(s.commands[guildID][:i], s.commands[guildID][i+1:]...)
+			return nil
+		}
+	}
+	return RESTError(http.StatusNotFound, discordgo.ErrCodeUnknownApplicationCommand, "Unknown application command")
+}
+
+// RESTError builds the error discordgo returns for a failed request, for use with
+// FailNext.
+func RESTError(status, code int, message string) *discordgo.RESTError {
+	body, _ := json.Marshal(discordgo.APIErrorMessage{Code: code, Message: message})
+	return &discordgo.RESTError{
+		Response: &http.Response{
+			StatusCode: status,
+			Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		},
+		ResponseBody: body,
+		Message:      &discordgo.APIErrorMessage{Code: code, Message: message},
+	}
+}
+
+// failure pops the next simulated error for method. s.mu must be held.
+func (s *Session) failure(method string) error {
+	queued := s.failures[method]
+	if len(queued) == 0 {
+		return nil
+	}
+	s.failures[method] = queued[1:]
+	return queued[0]
+}
+
+// newID returns a unique snowflake-like ID. s.mu must be held.
+func (s *Session) newID() string {
+	s.nextID++
+	return strconv.Itoa(s.nextID)
+}
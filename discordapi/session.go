@@ -0,0 +1,40 @@
+// Package discordapi describes the part of the Discord API the bot sends messages and
+// manages commands through, so that code can run against a fake session.
+package discordapi
+
+import "github.com/bwmarrin/discordgo"
+
+// Session is the subset of *discordgo.Session used outside of the gateway connection.
+// The method signatures match discordgo's so a live session only needs BotUserID added.
+type Session interface {
+	// BotUserID returns the bot's user ID, which is also the application ID commands
+	// are registered under.
+	BotUserID() string
+
+	ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error
+	FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error)
+
+	ApplicationCommands(appID, guildID string, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error)
+	ApplicationCommandCreate(appID, guildID string, cmd *discordgo.ApplicationCommand, options ...discordgo.RequestOption) (*discordgo.ApplicationCommand, error)
+	ApplicationCommandEdit(appID, guildID, cmdID string, cmd *discordgo.ApplicationCommand, options ...discordgo.RequestOption) (*discordgo.ApplicationCommand, error)
+	ApplicationCommandDelete(appID, guildID, cmdID string, options ...discordgo.RequestOption) error
+}
+
+// live adapts a gateway session to Session.
+type live struct {
+	*discordgo.Session
+}
+
+// Wrap returns s as a Session. s must have received its Ready event, which is where
+// the bot's user ID comes from.
+func Wrap(s *discordgo.Session) Session {
+	return live{s}
+}
+
+func (l live) BotUserID() string {
+	return l.State.User.ID
+}
@@ -8,7 +8,10 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-var Log *logrus.Logger
+var (
+	Log     *logrus.Logger
+	logFile *os.File
+)
 
 func init() {
 	Log = logrus.New()
@@ -21,10 +24,16 @@ func init() {
 		Log.WithError(err).Fatal("Failed to create log directory")
 	}
 	logFileName := logDir + time.Now().Format("2006-01-02") + ".txt"
-	logFile, err := os.OpenFile(logFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	logFile, err = os.OpenFile(logFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	if err != nil {
 		Log.WithError(err).Fatal("Failed to open log file")
 	}
 	mw := io.MultiWriter(os.Stdout, logFile)
 	Log.SetOutput(mw)
 }
+
+// ToStderr writes the console copy of the log to stderr instead of stdout, so that
+// command line tools can print their results to stdout.
+func ToStderr() {
+	Log.SetOutput(io.MultiWriter(os.Stderr, logFile))
+}
@@ -12,19 +12,41 @@ type Account struct {
 	Title                  string // The title of the account.
 	LastStatus             Status `gorm:"default:unknown"` // The last known status of the account.
 	LastCheck              int64  `gorm:"default:0"`       // The timestamp of the last check performed on the account.
-	LastNotification       int64  // The timestamp of the last daily notification sent out on the account.
+	LastNotification       int64  // The timestamp of the last digest the account was included in.
 	LastCookieNotification int64  // The timestamp of the last notification sent out on the account for an expired ssocookie.
-	SSOCookie              string // The SSO cookie associated with the account.
-	Created                string // The timestamp of when the account was created on Activision.
-	IsExpiredCookie        bool   `gorm:"default:false"`   // A flag indicating if the SSO cookie has expired.
-	NotificationType       string `gorm:"default:channel"` // User preference for location of notifications either channel or dm
+	SSOCookie              string `gorm:"serializer:cookie"` // The SSO cookie associated with the account, encrypted when COOKIE_ENCRYPTION_KEY is set.
+	ActivisionCreated      int64  `gorm:"default:0"`         // The timestamp of when the account was created on Activision, 0 until its profile has been fetched.
+	IsExpiredCookie        bool   `gorm:"default:false"`     // A flag indicating if the SSO cookie has expired.
+	NotificationType       string `gorm:"default:channel"`   // User preference for location of notifications either channel or dm
+	NextCheckAt            int64  `gorm:"index;default:0"`   // The timestamp at which the account is next due to be checked.
+	CheckInterval          int64  `gorm:"default:0"`         // A custom check interval in minutes, 0 uses the configured default.
+	LeaseOwner             string `gorm:"size:128"`          // The ID of the bot instance currently working on the account.
+	LeaseExpiresAt         int64  `gorm:"index;default:0"`   // The timestamp at which the current lease on the account expires.
+	MutedUntil             int64  `gorm:"default:0"`         // The timestamp until which the account is left out of digests and cookie reminders are suppressed.
+	MuteBanAlerts          bool   `gorm:"default:false"`     // A flag indicating if ban alerts are suppressed while the account is muted.
+	PausedUntil            int64  `gorm:"index;default:0"`   // The timestamp until which the account is not checked at all.
+	LastRecheckAt          int64  `gorm:"default:0"`         // The timestamp of the owner's last manual recheck, used to rate limit the Recheck now button.
+	CookieExpiresAt        int64  `gorm:"default:0"`         // The expiry timestamp embedded in the SSO cookie, 0 if it could not be read.
+	CookieWarningLevel     int    `gorm:"default:0"`         // The number of expiry reminders already sent for the current cookie.
+	AutoClaimRewards       bool   `gorm:"default:false"`     // A flag indicating if newly published reward codes are claimed automatically.
+	HeaderProfile          string // The name of the request header profile used for the account, empty for the configured default or rotation.
 }
 
 type Ban struct {
 	gorm.Model
-	Account   Account // The account that has been banned.
-	AccountID uint    // The ID of the banned account.
-	Status    Status  // The status of the ban.
+	Account        Account // The account that has been banned.
+	AccountID      uint    // The ID of the banned account.
+	Status         Status  // The status of the ban.
+	PreviousStatus Status  // The status of the account before this change.
+	BanWaveID      uint    `gorm:"index"` // The suspected ban wave this change was detected in, 0 if none.
+}
+
+type BanWave struct {
+	gorm.Model
+	Changes    int    // The number of accounts that changed to a ban within the window when the wave was detected.
+	Checked    int    // The number of accounts checked within the window when the wave was detected.
+	ResolvedAt int64  // The timestamp at which the wave was confirmed or discarded, 0 while it is being verified.
+	Outcome    string // confirmed for a real ban wave, incident for an API problem, empty while being verified.
 }
 
 type Status string
@@ -36,3 +58,99 @@ const (
 	StatusUnknown       Status = "unknown"        // The status of the account is unknown.
 	StatusInvalidCookie Status = "invalid_cookie" // The account has an invalid SSO cookie.
 )
+
+type Consent struct {
+	gorm.Model
+	UserID        string `gorm:"uniqueIndex;size:32"` // The ID of the user who gave consent.
+	PolicyVersion int    // The version of the privacy policy the user accepted.
+	AcceptedAt    int64  // The timestamp of when the user accepted the privacy policy.
+}
+
+type Lock struct {
+	Name      string `gorm:"primaryKey;size:64"` // The name of the singleton job the lock guards.
+	Owner     string `gorm:"size:128"`           // The ID of the bot instance holding the lock.
+	ExpiresAt int64  // The timestamp (in milliseconds) at which the lock expires unless renewed.
+}
+
+type GuildSettings struct {
+	gorm.Model
+	GuildID                 string `gorm:"uniqueIndex;size:32"` // The ID of the guild the settings belong to.
+	AlertsChannelID         string // The channel channel-mode notifications are sent to instead of the channel an account was added from.
+	PingRoleID              string // A role mentioned alongside the owner when a ban is detected.
+	AllowedRoleIDs          string // A comma separated list of roles allowed to use the bot, empty allows everyone.
+	BanWaveAnnouncements    bool   // A flag indicating if confirmed ban waves are announced in the alerts channel.
+	AccountLimit            int    `gorm:"default:0"`       // The maximum number of accounts each member may add in the guild, 0 for no limit.
+	DefaultNotificationType string `gorm:"default:channel"` // The notification type given to newly added accounts, either channel or dm.
+}
+
+type UserSettings struct {
+	gorm.Model
+	UserID               string `gorm:"uniqueIndex;size:32"` // The ID of the user the settings belong to.
+	Timezone             string // The IANA name of the user's timezone, e.g. Europe/London.
+	QuietHoursEnabled    bool   // A flag indicating if non-critical notifications are held during quiet hours.
+	QuietStart           int    // The hour (0-23, in the user's timezone) quiet hours start.
+	QuietEnd             int    // The hour (0-23, in the user's timezone) quiet hours end.
+	PermabanBreaksQuiet  bool   // A flag indicating if permanent ban alerts are delivered during quiet hours.
+	ShadowbanBreaksQuiet bool   // A flag indicating if shadowban alerts are delivered during quiet hours.
+	DigestSchedule       string // How often the account digest is sent: daily, weekly or off. Empty uses the configured default.
+	LastDigestAt         int64  // The timestamp of the last digest sent to the user.
+	NextDigestAt         int64  // The timestamp at which the next digest is due.
+}
+
+type GameBan struct {
+	gorm.Model
+	AccountID   uint   `gorm:"index"` // The ID of the banned account.
+	Title       string // The game the ban applies to.
+	Enforcement string // The enforcement reported by the ban API, PERMANENT or UNDER_REVIEW.
+	CanAppeal   bool   // A flag indicating if the ban API reports that the ban can be appealed.
+	AppealedAt  int64  // The timestamp at which the owner reported submitting an appeal, 0 if not appealed.
+	ResolvedAt  int64  // The timestamp at which the ban was lifted or finalised, 0 while it is active.
+	Resolution  string // How the ban ended: lifted or finalised.
+}
+
+type AuditLog struct {
+	gorm.Model
+	AccountID uint   `gorm:"index"` // The ID of the account the entry is about.
+	Action    string // What happened, e.g. cookie_rotated.
+	Detail    string // A human readable description, never containing secrets.
+}
+
+type AccountProfile struct {
+	gorm.Model
+	AccountID uint   `gorm:"uniqueIndex"` // The ID of the account the profile belongs to.
+	Username  string // The Activision username.
+	BattleNet string // The linked Battle.net username, empty if not linked.
+	PSN       string // The linked PlayStation Network username, empty if not linked.
+	Xbox      string // The linked Xbox gamertag, empty if not linked.
+	Steam     string // The linked Steam username, empty if not linked.
+	FetchedAt int64  // The timestamp at which the profile was last fetched.
+}
+
+type RewardCode struct {
+	gorm.Model
+	Code        string `gorm:"uniqueIndex;size:32"` // The code redeemed on the Call of Duty website.
+	Description string // What the code unlocks, as entered by the bot owner.
+	AddedBy     string // The ID of the bot owner who added the code.
+	AutoClaimed bool   `gorm:"index;default:false"` // A flag indicating if the code has been claimed for every account with auto-claim on.
+}
+
+type RewardClaim struct {
+	gorm.Model
+	AccountID    uint   `gorm:"uniqueIndex:idx_reward_claim"` // The ID of the account the code was redeemed for.
+	RewardCodeID uint   `gorm:"uniqueIndex:idx_reward_claim"` // The ID of the redeemed code.
+	Result       string // The outcome of the last attempt: claimed, already_claimed, invalid_code or failed.
+	Detail       string // What was unlocked, or why the attempt failed.
+	Attempts     int    // How many times the code has been redeemed for the account.
+}
+
+type Notification struct {
+	gorm.Model
+	AccountID      uint   `gorm:"index"`     // The ID of the account the notification is about.
+	UserID         string `gorm:"size:32"`   // When set, the notification is sent to this user by DM instead of the account's channel.
+	Content        string `gorm:"type:text"` // The message content, including any mentions.
+	Embed          string `gorm:"type:text"` // The JSON encoded embed of the message.
+	DeliverAt      int64  `gorm:"index"`     // The timestamp at which the notification should be sent.
+	LeaseOwner     string `gorm:"size:128"`  // The ID of the bot instance currently sending the notification.
+	LeaseExpiresAt int64  `gorm:"default:0"` // The timestamp at which the lease on the notification expires.
+	BanWaveID      uint   `gorm:"index"`     // The suspected ban wave the notification is held for, 0 if it is not held.
+}
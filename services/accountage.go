@@ -0,0 +1,34 @@
+package services
+
+import "time"
+
+// AccountAge returns the calendar difference between created and now in whole years,
+// months and days. A month is counted from a date to the same date in the next month,
+// or to the last day of that month when it is shorter, so Jan 31 to Feb 28 is one month.
+func AccountAge(created, now time.Time) (years, months, days int) {
+	created = created.UTC()
+	now = now.UTC()
+	if !now.After(created) {
+		return 0, 0, 0
+	}
+
+	total := (now.Year()-created.Year())*12 + int(now.Month()-created.Month())
+	anchor := addMonthsClamped(created, total)
+	if anchor.After(now) {
+		total--
+		anchor = addMonthsClamped(created, total)
+	}
+	days = int(now.Sub(anchor) / (24 * time.Hour))
+	return total / 12, total % 12, days
+}
+
+// addMonthsClamped adds months to t, keeping the day of the month but clamping it to
+// the length of the resulting month. time.AddDate would roll Jan 31 over to March.
+func addMonthsClamped(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
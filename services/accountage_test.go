@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day, hour int) time.Time {
+	return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestAccountAge(t *testing.T) {
+	tests := []struct {
+		name                string
+		created, now        time.Time
+		years, months, days int
+	}{
+		{"same instant", date(2020, 5, 10, 0), date(2020, 5, 10, 0), 0, 0, 0},
+		{"created after now", date(2021, 1, 1, 0), date(2020, 1, 1, 0), 0, 0, 0},
+		{"whole years", date(2015, 6, 1, 0), date(2020, 6, 1, 0), 5, 0, 0},
+		{"days within a month", date(2020, 3, 15, 0), date(2021, 3, 14, 0), 0, 11, 27},
+		{"month end clamps to shorter month", date(2023, 1, 31, 0), date(2023, 2, 28, 0), 0, 1, 0},
+		{"month end before the clamped date", date(2024, 1, 31, 0), date(2024, 2, 28, 0), 0, 0, 28},
+		{"month end clamps to leap day", date(2024, 1, 31, 0), date(2024, 2, 29, 0), 0, 1, 0},
+		{"clamping does not carry over", date(2022, 12, 31, 0), date(2023, 2, 28, 0), 0, 2, 0},
+		{"back to the full month end", date(2022, 12, 31, 0), date(2023, 3, 31, 0), 0, 3, 0},
+		{"leap day anniversary in a common year", date(2020, 2, 29, 0), date(2021, 2, 28, 0), 1, 0, 0},
+		{"day after leap day anniversary", date(2020, 2, 29, 0), date(2021, 3, 1, 0), 1, 0, 1},
+		{"leap day to leap day", date(2020, 2, 29, 0), date(2024, 2, 29, 0), 4, 0, 0},
+		{"earlier time of day", date(2020, 1, 1, 12), date(2021, 1, 1, 11), 0, 11, 30},
+		{"other timezone", time.Date(2020, 1, 1, 23, 0, 0, 0, time.FixedZone("UTC-5", -5*3600)), date(2020, 2, 2, 4), 0, 1, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			years, months, days := AccountAge(test.created, test.now)
+			if years != test.years || months != test.months || days != test.days {
+				t.Errorf("AccountAge(%s, %s) = %dy %dm %dd, want %dy %dm %dd", test.created, test.now,
+					years, months, days, test.years, test.months, test.days)
+			}
+		})
+	}
+}
+
+func TestAddMonthsClamped(t *testing.T) {
+	tests := []struct {
+		from   time.Time
+		months int
+		want   time.Time
+	}{
+		{date(2023, 1, 31, 0), 1, date(2023, 2, 28, 0)},
+		{date(2024, 1, 31, 0), 1, date(2024, 2, 29, 0)},
+		{date(2023, 3, 31, 0), 1, date(2023, 4, 30, 0)},
+		{date(2023, 11, 30, 0), 3, date(2024, 2, 29, 0)},
+		{date(2020, 2, 29, 0), 12, date(2021, 2, 28, 0)},
+		{date(2023, 5, 15, 8), 0, date(2023, 5, 15, 8)},
+	}
+	for _, test := range tests {
+		if got := addMonthsClamped(test.from, test.months); !got.Equal(test.want) {
+			t.Errorf("addMonthsClamped(%s, %d) = %s, want %s", test.from, test.months, got, test.want)
+		}
+	}
+}
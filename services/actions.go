@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/models"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Custom ID prefixes for the buttons attached to account notifications. Each custom ID
+// is "<prefix>:<account id>" so the buttons keep working after the bot restarts.
+const (
+	ActionRecheck     = "acct_recheck"
+	ActionUpdate      = "acct_update"
+	ActionUpdateModal = "acct_update_modal"
+	ActionHistory     = "acct_history"
+	ActionSnooze      = "acct_snooze"
+	ActionStop        = "acct_stop"
+	ActionStopConfirm = "acct_stop_confirm"
+	ActionStopCancel  = "acct_stop_cancel"
+)
+
+// AccountActionComponents returns the row of buttons attached to every notification
+// about an account.
+func AccountActionComponents(accountID uint) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "Recheck now", Style: discordgo.PrimaryButton, CustomID: ActionCustomID(ActionRecheck, accountID)},
+				discordgo.Button{Label: "Update cookie", Style: discordgo.SecondaryButton, CustomID: ActionCustomID(ActionUpdate, accountID)},
+				discordgo.Button{Label: "View history", Style: discordgo.SecondaryButton, CustomID: ActionCustomID(ActionHistory, accountID)},
+				discordgo.Button{Label: "Snooze 24h", Style: discordgo.SecondaryButton, CustomID: ActionCustomID(ActionSnooze, accountID)},
+				discordgo.Button{Label: "Stop monitoring", Style: discordgo.DangerButton, CustomID: ActionCustomID(ActionStop, accountID)},
+			},
+		},
+	}
+}
+
+func ActionCustomID(action string, accountID uint) string {
+	return fmt.Sprintf("%s:%d", action, accountID)
+}
+
+// RecheckCooldown is how long an owner has to wait between two manual rechecks of the
+// same account.
+const RecheckCooldown = 5 * time.Minute
+
+var (
+	ErrAccountPaused   = errors.New("account is paused")
+	ErrRecheckCooldown = errors.New("account was rechecked recently")
+)
+
+// RequestRecheck queues a manual recheck of the account. The check is run by the
+// scheduler, so it is leased like any other check and never runs twice at once. It
+// fails if the account is paused or was rechecked less than RecheckCooldown ago.
+func RequestRecheck(account models.Account) error {
+	now := time.Now()
+	result := database.DB.Model(&models.Account{}).
+		Where("id = ? AND paused_until <= ? AND last_recheck_at <= ?", account.ID, now.Unix(), now.Add(-RecheckCooldown).Unix()).
+		Update("last_recheck_at", now.Unix())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if account.PausedUntil > now.Unix() {
+			return ErrAccountPaused
+		}
+		return ErrRecheckCooldown
+	}
+	EnqueuePriorityCheck(account.ID)
+	return nil
+}
+
+// WaitForCheck waits up to timeout for a check of the account that finished after
+// since, the time the recheck was requested, and returns the account as that check left
+// it. Only accounts with a valid cookie are waited for, so a cookie that turned invalid
+// also means a check finished. It reports false if no check finished in time, e.g.
+// because the Activision API is unavailable.
+func WaitForCheck(accountID uint, since int64, timeout time.Duration) (models.Account, bool) {
+	deadline := time.Now().Add(timeout)
+	for {
+		var account models.Account
+		if err := database.DB.First(&account, accountID).Error; err != nil {
+			return account, false
+		}
+		if account.LastCheck > since || account.IsExpiredCookie {
+			return account, true
+		}
+		if time.Now().After(deadline) {
+			return account, false
+		}
+		time.Sleep(time.Second)
+	}
+}
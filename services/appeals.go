@@ -0,0 +1,175 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	ResolutionLifted    = "lifted"
+	ResolutionFinalised = "finalised"
+)
+
+// ErrNoActiveBan is returned when an appeal is recorded for an account without a
+// matching active ban.
+var ErrNoActiveBan = errors.New("no active ban to appeal")
+
+// ActiveGameBans returns the bans of an account that have not been lifted or finalised.
+func ActiveGameBans(accountID uint) []models.GameBan {
+	var bans []models.GameBan
+	database.DB.Where("account_id = ? AND resolved_at = ?", accountID, 0).Order("title").Find(&bans)
+	return bans
+}
+
+// RecordAppeal stores that the owner submitted an appeal for the account's active bans,
+// or only the ban for the given game when title is not empty.
+func RecordAppeal(accountID uint, title string) ([]models.GameBan, error) {
+	var appealed []models.GameBan
+	now := time.Now().Unix()
+	for _, ban := range ActiveGameBans(accountID) {
+		if title != "" && !strings.EqualFold(ban.Title, title) {
+			continue
+		}
+		ban.AppealedAt = now
+		if err := database.DB.Model(&ban).Update("appealed_at", now).Error; err != nil {
+			return nil, err
+		}
+		appealed = append(appealed, ban)
+	}
+	if len(appealed) == 0 {
+		return nil, ErrNoActiveBan
+	}
+	return appealed, nil
+}
+
+// syncGameBans stores the game bans reported by a check and tells the owner when an
+// appealed ban has been lifted or finalised.
+func syncGameBans(account models.Account, reported []BanDetail, discord discordapi.Session) {
+	active := ActiveGameBans(account.ID)
+	byTitle := make(map[string]models.GameBan, len(active))
+	for _, ban := range active {
+		byTitle[ban.Title] = ban
+	}
+
+	now := time.Now()
+	for _, detail := range reported {
+		ban, ok := byTitle[detail.Title]
+		delete(byTitle, detail.Title)
+		if !ok {
+			ban = models.GameBan{AccountID: account.ID, Title: detail.Title, Enforcement: detail.Enforcement, CanAppeal: detail.CanAppeal}
+			if err := database.DB.Create(&ban).Error; err != nil {
+				logger.Log.WithError(err).Error("Failed to store game ban for account", account.Title)
+			}
+			continue
+		}
+
+		finalised := appealFinalised(ban, detail)
+		updates := map[string]interface{}{"enforcement": detail.Enforcement, "can_appeal": detail.CanAppeal}
+		if finalised {
+			updates["resolved_at"] = now.Unix()
+			updates["resolution"] = ResolutionFinalised
+		}
+		if err := database.DB.Model(&ban).Updates(updates).Error; err != nil {
+			logger.Log.WithError(err).Error("Failed to update game ban for account", account.Title)
+			continue
+		}
+		if finalised {
+			notifyAppealResolved(account, ban, ResolutionFinalised, now, discord)
+		}
+	}
+
+	// Bans that are no longer reported have been lifted.
+	for _, ban := range byTitle {
+		err := database.DB.Model(&ban).Updates(map[string]interface{}{
+			"resolved_at": now.Unix(),
+			"resolution":  ResolutionLifted,
+		}).Error
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to mark game ban as lifted for account", account.Title)
+			continue
+		}
+		if ban.AppealedAt > 0 {
+			notifyAppealResolved(account, ban, ResolutionLifted, now, discord)
+		}
+	}
+}
+
+// appealFinalised reports whether a check made an appealed ban final. Only a move to
+// permanent enforcement ends the appeal; a ban that merely stops being appealable can
+// still be lifted.
+func appealFinalised(ban models.GameBan, detail BanDetail) bool {
+	return ban.AppealedAt > 0 && ban.Enforcement != "PERMANENT" && detail.Enforcement == "PERMANENT"
+}
+
+func notifyAppealResolved(account models.Account, ban models.GameBan, resolution string, now time.Time, discord discordapi.Session) {
+	took := FormatElapsed(now.Sub(time.Unix(ban.AppealedAt, 0)))
+	logger.Log.Infof("Appeal for %s on account %s was resolved (%s) after %s", ban.Title, account.Title, resolution, took)
+
+	embed := &discordgo.MessageEmbed{
+		Title:     fmt.Sprintf("%s - Appeal Resolved", account.Title),
+		Color:     0x00ff00,
+		Timestamp: now.Format(time.RFC3339),
+	}
+	if resolution == ResolutionLifted {
+		embed.Description = fmt.Sprintf("The ban on %s was lifted %s after you appealed it.", ban.Title, took)
+	} else {
+		embed.Color = 0xff0000
+		embed.Description = fmt.Sprintf("The ban on %s was made permanent %s after you appealed it.", ban.Title, took)
+	}
+	notifyOwner(account, discord, fmt.Sprintf("<@%s>", account.UserID), embed, false)
+}
+
+// GameBanSummary describes each ban with its appeal eligibility, one line per game.
+func GameBanSummary(bans []models.GameBan) string {
+	lines := make([]string, len(bans))
+	for i, ban := range bans {
+		enforcement := "Shadowban"
+		if ban.Enforcement == "PERMANENT" {
+			enforcement = "Permanent ban"
+		}
+		appeal := "cannot be appealed"
+		switch {
+		case ban.AppealedAt > 0:
+			appeal = fmt.Sprintf("appealed <t:%d:R>", ban.AppealedAt)
+		case ban.CanAppeal:
+			appeal = "can be appealed, use /appeal once you have"
+		}
+		lines[i] = fmt.Sprintf("%s: %s, %s", ban.Title, enforcement, appeal)
+		if ban.ResolvedAt > 0 {
+			lines[i] = fmt.Sprintf("%s: %s <t:%d:R>", ban.Title, ban.Resolution, ban.ResolvedAt)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FormatElapsed renders a duration in days and hours, e.g. "3 days 4 hours".
+func FormatElapsed(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	switch {
+	case days == 0 && hours == 0:
+		return "less than an hour"
+	case days == 0:
+		return plural(hours, "hour")
+	case hours == 0:
+		return plural(days, "day")
+	default:
+		return plural(days, "day") + " " + plural(hours, "hour")
+	}
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"codstatusbot2.0/config"
+	"codstatusbot2.0/database/fakedb"
+	"codstatusbot2.0/discordapi/fake"
+	"codstatusbot2.0/models"
+)
+
+func TestFormatElapsed(t *testing.T) {
+	tests := []struct {
+		elapsed time.Duration
+		want    string
+	}{
+		{0, "less than an hour"},
+		{59 * time.Minute, "less than an hour"},
+		{time.Hour, "1 hour"},
+		{5*time.Hour + 30*time.Minute, "5 hours"},
+		{24 * time.Hour, "1 day"},
+		{25 * time.Hour, "1 day 1 hour"},
+		{3*24*time.Hour + 4*time.Hour, "3 days 4 hours"},
+		{14 * 24 * time.Hour, "14 days"},
+	}
+	for _, test := range tests {
+		if got := FormatElapsed(test.elapsed); got != test.want {
+			t.Errorf("FormatElapsed(%v) = %q, want %q", test.elapsed, got, test.want)
+		}
+	}
+}
+
+func TestSyncGameBans(t *testing.T) {
+	Configure(config.Default())
+	appealedAt := time.Now().Add(-50 * time.Hour).Unix()
+	tests := []struct {
+		name         string
+		enforcement  string // The enforcement of the stored ban.
+		appealedAt   int64
+		reported     []BanDetail
+		quietHours   bool
+		wantTitle    string // The title of the notification sent, empty if none is sent.
+		wantHeld     bool
+		wantResolved bool
+	}{
+		{name: "still under review", enforcement: "UNDER_REVIEW", appealedAt: appealedAt,
+			reported: []BanDetail{{Title: "mw2", Enforcement: "UNDER_REVIEW", CanAppeal: true}}},
+		{name: "no longer appealable", enforcement: "UNDER_REVIEW", appealedAt: appealedAt,
+			reported: []BanDetail{{Title: "mw2", Enforcement: "UNDER_REVIEW"}}},
+		{name: "made permanent", enforcement: "UNDER_REVIEW", appealedAt: appealedAt,
+			reported:  []BanDetail{{Title: "mw2", Enforcement: "PERMANENT"}},
+			wantTitle: "Appeal Resolved", wantResolved: true},
+		{name: "made permanent without an appeal", enforcement: "UNDER_REVIEW",
+			reported: []BanDetail{{Title: "mw2", Enforcement: "PERMANENT"}}},
+		{name: "lifted", enforcement: "PERMANENT", appealedAt: appealedAt,
+			wantTitle: "Appeal Resolved", wantResolved: true},
+		{name: "lifted without an appeal", enforcement: "PERMANENT", wantResolved: true},
+		{name: "lifted during quiet hours", enforcement: "PERMANENT", appealedAt: appealedAt, quietHours: true,
+			wantHeld: true, wantResolved: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := fakedb.Use(t)
+			db.Return("game_bans", map[string]driver.Value{
+				"id": int64(3), "account_id": int64(1), "title": "mw2", "enforcement": test.enforcement,
+				"can_appeal": true, "appealed_at": test.appealedAt, "resolved_at": int64(0), "resolution": "",
+			})
+			if test.quietHours {
+				hour := time.Now().UTC().Hour()
+				db.Return("user_settings", map[string]driver.Value{
+					"user_id": "user-1", "timezone": "UTC", "quiet_hours_enabled": true,
+					"quiet_start": int64(hour), "quiet_end": int64((hour + 2) % 24),
+				})
+			}
+			session := fake.NewSession()
+			account := models.Account{Title: "main", UserID: "user-1", ChannelID: "channel-1", NotificationType: "channel"}
+			account.ID = 1
+
+			syncGameBans(account, test.reported, session)
+
+			messages := session.Messages()
+			if test.wantTitle == "" {
+				if len(messages) != 0 {
+					t.Errorf("sent %d messages, want none", len(messages))
+				}
+			} else if len(messages) != 1 || !strings.Contains(messages[0].Embeds[0].Title, test.wantTitle) {
+				t.Errorf("sent %+v, want one %q notification", messages, test.wantTitle)
+			} else if !strings.Contains(messages[0].Embeds[0].Description, "2 days 2 hours") {
+				t.Errorf("description = %q, want how long the appeal took", messages[0].Embeds[0].Description)
+			}
+
+			held, resolved := false, false
+			for _, exec := range db.Execs() {
+				held = held || strings.HasPrefix(exec.Query, "INSERT INTO `notifications`")
+				resolved = resolved || (strings.HasPrefix(exec.Query, "UPDATE `game_bans`") && strings.Contains(exec.Query, "`resolution`"))
+			}
+			if held != test.wantHeld {
+				t.Errorf("notification held = %v, want %v", held, test.wantHeld)
+			}
+			if resolved != test.wantResolved {
+				t.Errorf("ban resolved = %v, want %v", resolved, test.wantResolved)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// AutoClaimJob is the name AutoClaimRewards runs under with RunSingleton.
+const AutoClaimJob = "autoclaim"
+
+// autoClaimRetryDelay is how long auto-claim waits before retrying a code that could
+// not be claimed for an account, e.g. because Activision could not be reached.
+const autoClaimRetryDelay = time.Hour
+
+// autoClaimMaxAttempts is how many times auto-claim tries a code for an account before
+// it gives up and tells the owner.
+const autoClaimMaxAttempts = 5
+
+// AutoClaimRewards claims newly published reward codes for every account that has
+// auto-claim turned on and a valid cookie, then sends each owner one summary. It runs
+// on a single instance through RunSingleton and renews its lock after every account;
+// the claim ledger makes it safe to resume after a restart. A code is only retired
+// once it has been claimed, or found already redeemed or invalid, for every account.
+func AutoClaimRewards(router SessionRouter) {
+	var codes []models.RewardCode
+	if err := database.DB.Where("auto_claimed = ?", false).Order("id").Find(&codes).Error; err != nil {
+		logger.Log.WithError(err).Error("Failed to fetch new reward codes")
+		return
+	}
+	if len(codes) == 0 {
+		return
+	}
+	logger.Log.Infof("Auto-claiming %d new reward code(s)", len(codes))
+
+	now := time.Now()
+	var accounts []models.Account
+	err := database.DB.
+		Where("auto_claim_rewards = ? AND is_expired_cookie = ? AND paused_until <= ?", true, false, now.Unix()).
+		Order("user_id, title").
+		Find(&accounts).Error
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to fetch accounts for auto-claim")
+		return
+	}
+
+	summaries := make(map[string][]string)
+	var users []string
+	firstAccount := make(map[string]models.Account)
+	for _, account := range accounts {
+		if !RenewSingleton(AutoClaimJob) {
+			logger.Log.Warn("Lost the auto-claim lock to another instance, stopping this run")
+			return
+		}
+		retryable, failures := retryableCodes(account, codes, now)
+		reports, err := claimCodes(account, retryable)
+		if err != nil {
+			logger.Log.WithError(err).Errorf("Failed to auto-claim rewards for account %s", account.Title)
+			continue
+		}
+		lines := autoClaimLines(reports, failures)
+		if len(lines) == 0 {
+			continue
+		}
+		if _, ok := summaries[account.UserID]; !ok {
+			users = append(users, account.UserID)
+			firstAccount[account.UserID] = account
+		}
+		summaries[account.UserID] = append(summaries[account.UserID], fmt.Sprintf("**%s**\n%s", account.Title, strings.Join(lines, "\n")))
+	}
+
+	if finished := finishedCodes(codes, accounts); len(finished) > 0 {
+		if err := database.DB.Model(&models.RewardCode{}).Where("id IN ?", finished).Update("auto_claimed", true).Error; err != nil {
+			logger.Log.WithError(err).Error("Failed to mark reward codes as auto-claimed")
+		}
+	}
+
+	for _, userID := range users {
+		sendAutoClaimSummary(userID, firstAccount[userID], summaries[userID], router)
+	}
+}
+
+// retryableCodes leaves out the codes whose last claim for the account failed less than
+// autoClaimRetryDelay ago, so a failing account is not retried on every run, and the
+// codes auto-claim has given up on. It also returns how many times each code still
+// being retried has failed so far.
+func retryableCodes(account models.Account, codes []models.RewardCode, now time.Time) ([]models.RewardCode, map[uint]int) {
+	var failed []models.RewardClaim
+	err := database.DB.Where("account_id = ? AND result = ?", account.ID, ClaimFailed).Find(&failed).Error
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to fetch failed claims for account %s", account.Title)
+		return codes, nil
+	}
+	skip := make(map[uint]bool, len(failed))
+	failures := make(map[uint]int, len(failed))
+	for _, claim := range failed {
+		if claim.Attempts >= autoClaimMaxAttempts || claim.UpdatedAt.After(now.Add(-autoClaimRetryDelay)) {
+			skip[claim.RewardCodeID] = true
+		}
+		failures[claim.RewardCodeID] = claim.Attempts
+	}
+	var retryable []models.RewardCode
+	for _, code := range codes {
+		if !skip[code.ID] {
+			retryable = append(retryable, code)
+		}
+	}
+	return retryable, failures
+}
+
+// finishedCodes returns the IDs of the codes that no account needs to try again: every
+// account has claimed them, already had them, was told they are invalid or has run out
+// of attempts.
+func finishedCodes(codes []models.RewardCode, accounts []models.Account) []uint {
+	codeIDs := make([]uint, len(codes))
+	for i, code := range codes {
+		codeIDs[i] = code.ID
+	}
+	accountIDs := make([]uint, len(accounts))
+	for i, account := range accounts {
+		accountIDs[i] = account.ID
+	}
+
+	counts := make(map[uint]int)
+	if len(accountIDs) > 0 {
+		var rows []struct {
+			RewardCodeID uint
+			Count        int
+		}
+		err := database.DB.Model(&models.RewardClaim{}).
+			Select("reward_code_id, COUNT(*) AS count").
+			Where("reward_code_id IN ? AND account_id IN ?", codeIDs, accountIDs).
+			Where("result IN ? OR (result = ? AND attempts >= ?)",
+				[]string{ClaimSuccess, ClaimAlreadyRedeemed, ClaimInvalidCode}, ClaimFailed, autoClaimMaxAttempts).
+			Group("reward_code_id").
+			Scan(&rows).Error
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to count finished reward claims")
+			return nil
+		}
+		for _, row := range rows {
+			counts[row.RewardCodeID] = row.Count
+		}
+	}
+
+	var finished []uint
+	for _, id := range codeIDs {
+		if counts[id] >= len(accountIDs) {
+			finished = append(finished, id)
+		}
+	}
+	return finished
+}
+
+// autoClaimLines describes the codes attempted in this run, leaving out codes that had
+// already been claimed for the account earlier. A failed code is retried on a later run,
+// so its failure is only reported once auto-claim gives up on it; failures holds how
+// many times each code had failed before this run.
+func autoClaimLines(reports []ClaimReport, failures map[uint]int) []string {
+	var attempted []ClaimReport
+	for _, report := range reports {
+		if report.Skipped {
+			continue
+		}
+		if report.Outcome.Result == ClaimFailed {
+			if failures[report.Code.ID]+1 < autoClaimMaxAttempts {
+				continue
+			}
+			report.Outcome.Detail = fmt.Sprintf("%s, gave up after %d attempts (use /claimavailablerewards to try again)",
+				report.Outcome.Detail, autoClaimMaxAttempts)
+		}
+		attempted = append(attempted, report)
+	}
+	if len(attempted) == 0 {
+		return nil
+	}
+	return strings.Split(FormatClaimReport(attempted), "\n")
+}
+
+func sendAutoClaimSummary(userID string, account models.Account, sections []string, router SessionRouter) {
+	description := strings.Join(sections, "\n\n")
+	if len(description) > 4000 {
+		description = description[:3997] + "..."
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       "New Rewards Claimed",
+		Description: description,
+		Color:       0x00ff00,
+		Timestamp:   time.Now().Format(time.RFC3339),
+		Footer:      &discordgo.MessageEmbedFooter{Text: "Turn this off for an account with /autoclaim"},
+	}
+
+	if quietEnd, quiet := quietUntil(GetUserSettings(userID), time.Now()); quiet {
+		// The summary is tied to one of the accounts so it is dropped if they are all removed.
+		if err := hold(models.Notification{AccountID: account.ID, UserID: userID}, embed, quietEnd); err != nil {
+			logger.Log.WithError(err).Errorf("Failed to hold reward summary for user %s", userID)
+		}
+		return
+	}
+
+	discord := router.SessionForGuild("")
+	channel, err := discord.UserChannelCreate(userID)
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to create DM channel")
+		return
+	}
+	if _, err := discord.ChannelMessageSendEmbed(channel.ID, embed); err != nil {
+		logger.Log.WithError(err).Errorf("Failed to send reward summary to user %s", userID)
+	}
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"codstatusbot2.0/models"
+)
+
+func TestAutoClaimLines(t *testing.T) {
+	code := models.RewardCode{Code: "ABC"}
+	code.ID = 7
+	tests := []struct {
+		name     string
+		report   ClaimReport
+		failures int // How many times the code had failed before this run.
+		want     string
+	}{
+		{name: "claimed", report: ClaimReport{Code: code, Outcome: ClaimOutcome{Result: ClaimSuccess}}, want: "- ABC: claimed"},
+		{name: "invalid", report: ClaimReport{Code: code, Outcome: ClaimOutcome{Result: ClaimInvalidCode, Detail: "the code is not valid"}}, want: "- ABC: the code is not valid"},
+		{name: "claimed earlier", report: ClaimReport{Code: code, Outcome: ClaimOutcome{Result: ClaimSuccess}, Skipped: true}},
+		{name: "first failure", report: ClaimReport{Code: code, Outcome: ClaimOutcome{Result: ClaimFailed, Detail: "unexpected response"}}},
+		{name: "failure before the last attempt", report: ClaimReport{Code: code, Outcome: ClaimOutcome{Result: ClaimFailed, Detail: "unexpected response"}}, failures: autoClaimMaxAttempts - 2},
+		{name: "last attempt", report: ClaimReport{Code: code, Outcome: ClaimOutcome{Result: ClaimFailed, Detail: "unexpected response"}}, failures: autoClaimMaxAttempts - 1, want: "- ABC: unexpected response, gave up after 5 attempts"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines := autoClaimLines([]ClaimReport{test.report}, map[uint]int{code.ID: test.failures})
+			if test.want == "" {
+				if len(lines) != 0 {
+					t.Fatalf("autoClaimLines() = %q, want nothing reported", lines)
+				}
+				return
+			}
+			if len(lines) != 1 || !strings.HasPrefix(lines[0], test.want) {
+				t.Errorf("autoClaimLines() = %q, want one line starting with %q", lines, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	BanWaveConfirmed = "confirmed"
+	BanWaveIncident  = "incident"
+
+	// banWaveGiveUp is how many verify delays a wave may stay unverified, because
+	// every recheck failed, before its alerts are sent anyway.
+	banWaveGiveUp = 3
+)
+
+// pendingBanWave returns the ban wave that is currently being verified, if any.
+func pendingBanWave() (models.BanWave, bool) {
+	var wave models.BanWave
+	err := database.DB.Where("outcome = ?", "").Order("id").First(&wave).Error
+	return wave, err == nil
+}
+
+// banWaveFor returns the ID of the suspected ban wave a new change to a ban belongs
+// to, or 0 if the change looks like an ordinary ban. A wave is opened when the number
+// of accounts that changed to a ban within the configured window, including this one,
+// exceeds both the minimum and the configured share of the accounts checked.
+func banWaveFor(discord discordapi.Session, now time.Time) uint {
+	if cfg.BanWave.Window == 0 {
+		return 0
+	}
+	if wave, ok := pendingBanWave(); ok {
+		return wave.ID
+	}
+
+	since := now.Add(-cfg.BanWave.Window)
+	var changes, checked int64
+	database.DB.Model(&models.Ban{}).
+		Where("created_at >= ? AND status IN ? AND previous_status NOT IN ?", since,
+			[]models.Status{models.StatusShadowban, models.StatusPermaban},
+			[]models.Status{models.StatusShadowban, models.StatusPermaban}).
+		Count(&changes)
+	database.DB.Model(&models.Account{}).Where("last_check >= ?", since.Unix()).Count(&checked)
+	changes++
+	if changes < int64(cfg.BanWave.MinChanges) || changes*100 < checked*int64(cfg.BanWave.ThresholdPercent) {
+		return 0
+	}
+
+	wave := models.BanWave{Changes: int(changes), Checked: int(checked)}
+	if err := database.DB.Create(&wave).Error; err != nil {
+		logger.Log.WithError(err).Error("Failed to record suspected ban wave")
+		return 0
+	}
+	// The changes that led up to the wave were reported before it opened. They belong
+	// to it all the same, so they are verified and rolled back with the rest.
+	err := database.DB.Model(&models.Ban{}).
+		Where("created_at >= ? AND ban_wave_id = ? AND status IN ? AND previous_status NOT IN ?", since, 0,
+			[]models.Status{models.StatusShadowban, models.StatusPermaban},
+			[]models.Status{models.StatusShadowban, models.StatusPermaban}).
+		Update("ban_wave_id", wave.ID).Error
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to add earlier changes to ban wave %d", wave.ID)
+	}
+	logger.Log.Warnf("Suspected ban wave: %d of %d accounts checked in the last %s changed to a ban, holding alerts",
+		changes, checked, cfg.BanWave.Window)
+	alertOwners(discord, &discordgo.MessageEmbed{
+		Title: "Suspected Ban Wave",
+		Description: fmt.Sprintf("%d of the %d accounts checked in the last %s changed to a ban. Ban alerts are held while a sample of the accounts is rechecked <t:%d:R>.",
+			changes, checked, cfg.BanWave.Window, now.Add(cfg.BanWave.VerifyDelay).Unix()),
+		Color:     0xffff00,
+		Timestamp: now.Format(time.RFC3339),
+	})
+	return wave.ID
+}
+
+// ResolveBanWaves rechecks a sample of the accounts in each suspected ban wave once
+// its verify delay has passed. If most of them are still banned the held alerts are
+// sent, otherwise the changes are treated as an API incident and rolled back. It runs
+// on a single instance through RunSingleton.
+func ResolveBanWaves(router SessionRouter) {
+	now := time.Now()
+	var waves []models.BanWave
+	database.DB.Where("outcome = ? AND created_at <= ?", "", now.Add(-cfg.BanWave.VerifyDelay)).Find(&waves)
+	for _, wave := range waves {
+		resolveBanWave(wave, router, now)
+	}
+}
+
+func resolveBanWave(wave models.BanWave, router SessionRouter, now time.Time) {
+	var bans []models.Ban
+	if err := database.DB.Where("ban_wave_id = ?", wave.ID).Find(&bans).Error; err != nil {
+		logger.Log.WithError(err).Errorf("Failed to fetch the changes in ban wave %d", wave.ID)
+		return
+	}
+
+	banned, cleared := verifySample(bans)
+	outcome := BanWaveConfirmed
+	switch {
+	case banned+cleared == 0 && now.Sub(wave.CreatedAt) < banWaveGiveUp*cfg.BanWave.VerifyDelay:
+		logger.Log.Warnf("Could not recheck any account in ban wave %d, retrying later", wave.ID)
+		return
+	case cleared > banned:
+		outcome = BanWaveIncident
+	}
+
+	wave.Outcome = outcome
+	wave.ResolvedAt = now.Unix()
+	if err := database.DB.Save(&wave).Error; err != nil {
+		logger.Log.WithError(err).Errorf("Failed to save the outcome of ban wave %d", wave.ID)
+		return
+	}
+
+	embed := &discordgo.MessageEmbed{Timestamp: now.Format(time.RFC3339)}
+	if outcome == BanWaveConfirmed {
+		database.DB.Model(&models.Notification{}).Where("ban_wave_id = ?", wave.ID).Update("ban_wave_id", 0)
+		logger.Log.Warnf("Ban wave %d confirmed, releasing alerts for %d accounts", wave.ID, len(bans))
+		embed.Title = "Ban Wave Confirmed"
+		embed.Color = 0xff0000
+		embed.Description = fmt.Sprintf("%d of %d rechecked accounts are still banned. The held alerts for %d accounts are being sent.",
+			banned, banned+cleared, len(bans))
+		announceBanWave(router, len(bans), now)
+	} else {
+		rollBackBanWave(wave, bans, router, now)
+		logger.Log.Warnf("Ban wave %d discarded as an API incident, rolled back %d changes", wave.ID, len(bans))
+		embed.Title = "Ban Wave Discarded"
+		embed.Color = 0x00ff00
+		embed.Description = fmt.Sprintf("%d of %d rechecked accounts are no longer banned, so this looks like an Activision API incident. The %d held alerts were discarded and the accounts were restored to their previous status.",
+			cleared, banned+cleared, len(bans))
+	}
+	alertOwners(router.SessionForGuild(""), embed)
+}
+
+// verifySample rechecks a random sample of the accounts in a wave and returns how
+// many are still banned and how many are not. Accounts whose recheck fails are
+// counted in neither.
+func verifySample(bans []models.Ban) (banned, cleared int) {
+	sample := make([]models.Ban, len(bans))
+	copy(sample, bans)
+	rand.Shuffle(len(sample), func(i, j int) { sample[i], sample[j] = sample[j], sample[i] })
+	if len(sample) > cfg.BanWave.SampleSize {
+		sample = sample[:cfg.BanWave.SampleSize]
+	}
+
+	for _, ban := range sample {
+		var account models.Account
+		if err := database.DB.First(&account, ban.AccountID).Error; err != nil {
+			continue
+		}
+		status, err := CheckAccount(AccountCredentials(account))
+		if err != nil {
+			logger.Log.WithError(err).Errorf("Failed to recheck account %s for ban wave %d", account.Title, ban.BanWaveID)
+			continue
+		}
+		switch status {
+		case models.StatusShadowban, models.StatusPermaban:
+			banned++
+		case models.StatusGood:
+			cleared++
+		}
+	}
+	return banned, cleared
+}
+
+// rollBackBanWave restores the accounts in a discarded wave to their previous status
+// and drops the changes, the game bans they reported and the alerts held for them.
+// Owners whose alert went out before the wave was suspected are told it was wrong.
+func rollBackBanWave(wave models.BanWave, bans []models.Ban, router SessionRouter, now time.Time) {
+	var heldFor []uint
+	database.DB.Model(&models.Notification{}).Where("ban_wave_id = ?", wave.ID).Pluck("account_id", &heldFor)
+	held := make(map[uint]bool, len(heldFor))
+	for _, id := range heldFor {
+		held[id] = true
+	}
+
+	tx := database.DB.Begin()
+	for _, ban := range bans {
+		err := tx.Model(&models.Account{}).
+			Where("id = ? AND last_status = ?", ban.AccountID, ban.Status).
+			Update("last_status", ban.PreviousStatus).Error
+		if err != nil {
+			logger.Log.WithError(err).Errorf("Failed to restore the status of account %d", ban.AccountID)
+			tx.Rollback()
+			return
+		}
+		// The game bans are stored by the same check that recorded the change, just
+		// before it. The account was not banned before, so none of its active game
+		// bans predate the change.
+		err = tx.Unscoped().
+			Where("account_id = ? AND resolved_at = ? AND created_at >= ?", ban.AccountID, 0, ban.CreatedAt.Add(-time.Minute)).
+			Delete(&models.GameBan{}).Error
+		if err != nil {
+			logger.Log.WithError(err).Errorf("Failed to delete the game bans of account %d", ban.AccountID)
+			tx.Rollback()
+			return
+		}
+	}
+	if err := tx.Unscoped().Where("ban_wave_id = ?", wave.ID).Delete(&models.Ban{}).Error; err != nil {
+		logger.Log.WithError(err).Errorf("Failed to delete the changes in ban wave %d", wave.ID)
+		tx.Rollback()
+		return
+	}
+	if err := tx.Unscoped().Where("ban_wave_id = ?", wave.ID).Delete(&models.Notification{}).Error; err != nil {
+		logger.Log.WithError(err).Errorf("Failed to delete the alerts held for ban wave %d", wave.ID)
+		tx.Rollback()
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		logger.Log.WithError(err).Errorf("Failed to roll back ban wave %d", wave.ID)
+		return
+	}
+
+	for _, ban := range bans {
+		if held[ban.AccountID] {
+			continue
+		}
+		var account models.Account
+		if err := database.DB.First(&account, ban.AccountID).Error; err != nil {
+			continue
+		}
+		embed := &discordgo.MessageEmbed{
+			Title:       fmt.Sprintf("%s - False Alarm", account.Title),
+			Description: fmt.Sprintf("The earlier alert that account %s changed to %s was caused by a problem with Activision's API and has been withdrawn.", account.Title, ban.Status),
+			Color:       GetColorForStatus(ban.PreviousStatus, account.IsExpiredCookie),
+			Timestamp:   now.Format(time.RFC3339),
+		}
+		notifyOwner(account, router.SessionForGuild(notificationGuild(account)), "", embed, false)
+	}
+}
+
+// announceBanWave posts a short announcement in the alerts channel of every guild that
+// opted in with /serverconfig.
+func announceBanWave(router SessionRouter, accounts int, now time.Time) {
+	var guilds []models.GuildSettings
+	database.DB.Where("ban_wave_announcements = ? AND alerts_channel_id <> ?", true, "").Find(&guilds)
+	embed := &discordgo.MessageEmbed{
+		Title:       "Ban Wave Detected",
+		Description: fmt.Sprintf("Activision banned %d of the accounts monitored by this bot within %s. Owners of affected accounts are being notified.", accounts, cfg.BanWave.Window),
+		Color:       0xff0000,
+		Timestamp:   now.Format(time.RFC3339),
+	}
+	for _, guild := range guilds {
+		discord := router.SessionForGuild(guild.GuildID)
+		if _, err := discord.ChannelMessageSendEmbed(guild.AlertsChannelID, embed); err != nil {
+			logger.Log.WithError(err).Errorf("Failed to announce ban wave in guild %s", guild.GuildID)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"codstatusbot2.0/logger"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ErrCircuitOpen is returned instead of sending a request to Activision while the
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("activision API is unavailable, circuit breaker is open")
+
+// CircuitBreaker tracks the outcome of the most recent requests to the Activision API.
+// It opens when too many of them fail, after which requests are refused until a
+// canary request succeeds.
+type CircuitBreaker struct {
+	mu       sync.Mutex
+	results  []bool // A ring of the most recent outcomes, true for a failure.
+	next     int
+	filled   int
+	open     bool
+	openedAt time.Time
+	onChange func(open bool)
+}
+
+var (
+	activision     = &CircuitBreaker{}
+	breakerWatchMu sync.Mutex
+	breakerWatch   []func(open bool)
+)
+
+// OnActivisionStatusChange registers a function that is called whenever the circuit
+// breaker around the Activision API opens or closes, e.g. to update the bot presence.
+func OnActivisionStatusChange(fn func(open bool)) {
+	breakerWatchMu.Lock()
+	breakerWatch = append(breakerWatch, fn)
+	breakerWatchMu.Unlock()
+}
+
+// Allow reports whether a request may be sent.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.open
+}
+
+// Record adds the outcome of a request and opens the breaker once the failure rate
+// over the configured window reaches the threshold.
+func (b *CircuitBreaker) Record(failed bool) {
+	b.mu.Lock()
+	if b.open {
+		b.mu.Unlock()
+		return
+	}
+	if len(b.results) != cfg.Breaker.Window {
+		b.results = make([]bool, cfg.Breaker.Window)
+		b.next, b.filled = 0, 0
+	}
+	b.results[b.next] = failed
+	b.next = (b.next + 1) % len(b.results)
+	if b.filled < len(b.results) {
+		b.filled++
+	}
+
+	failures := 0
+	for _, result := range b.results[:b.filled] {
+		if result {
+			failures++
+		}
+	}
+	trip := b.filled >= cfg.Breaker.MinRequests && failures*100 >= b.filled*cfg.Breaker.FailurePercent
+	if trip {
+		logger.Log.Warnf("Opening circuit breaker: %d of the last %d Activision requests failed", failures, b.filled)
+		b.open = true
+		b.openedAt = time.Now()
+	}
+	b.mu.Unlock()
+	if trip {
+		b.changed(true)
+	}
+}
+
+// RetryIn returns how long until the next canary request, 0 if one is due or the
+// breaker is closed.
+func (b *CircuitBreaker) RetryIn() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return 0
+	}
+	if d := time.Until(b.openedAt.Add(cfg.Breaker.Cooldown)); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Probe sends a canary request once the cooldown has passed and closes the breaker
+// if it succeeds. It returns whether requests are allowed afterwards.
+func (b *CircuitBreaker) Probe() bool {
+	if b.Allow() {
+		return true
+	}
+	if b.RetryIn() > 0 {
+		return false
+	}
+
+	err := canaryRequest()
+	b.mu.Lock()
+	if err != nil {
+		logger.Log.WithError(err).Warn("Activision canary request failed, circuit breaker stays open")
+		b.openedAt = time.Now()
+		b.mu.Unlock()
+		return false
+	}
+	logger.Log.Info("Activision canary request succeeded, closing circuit breaker")
+	b.open = false
+	b.next, b.filled = 0, 0
+	b.mu.Unlock()
+	b.changed(false)
+	return true
+}
+
+func (b *CircuitBreaker) changed(open bool) {
+	if b.onChange != nil {
+		b.onChange(open)
+	}
+	breakerWatchMu.Lock()
+	watchers := append([]func(bool){}, breakerWatch...)
+	breakerWatchMu.Unlock()
+	for _, fn := range watchers {
+		fn(open)
+	}
+}
+
+// canaryRequest checks that the ban API answers without an authenticated session. Any
+// response below 500 means the API is up again.
+func canaryRequest() error {
+	req, err := newActivisionRequest("GET", url1, Credentials{}, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if failedStatus(resp.StatusCode) {
+		return fmt.Errorf("canary request returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// PingActivision reports whether the ban API is reachable, without going through the
+// circuit breaker.
+func PingActivision() error {
+	return canaryRequest()
+}
+
+// failedStatus reports whether a response status means the API itself is failing, as
+// opposed to the request being rejected for a bad cookie.
+func failedStatus(code int) bool {
+	return code >= http.StatusInternalServerError || code == http.StatusTooManyRequests
+}
+
+// doActivision sends a request to the Activision API through the circuit breaker and
+// records whether it failed. Responses with a failing status are returned as errors.
+func doActivision(req *http.Request, creds Credentials) (*http.Response, error) {
+	if !activision.Allow() {
+		return nil, ErrCircuitOpen
+	}
+	resp, err := sendThroughProxy(req, creds)
+	if errors.Is(err, ErrNoHealthyProxy) {
+		// The request never reached Activision, so it says nothing about the API.
+		return nil, err
+	}
+	if err != nil {
+		activision.Record(true)
+		return nil, err
+	}
+	if failedStatus(resp.StatusCode) {
+		activision.Record(true)
+		resp.Body.Close()
+		return nil, fmt.Errorf("activision API returned status %d", resp.StatusCode)
+	}
+	activision.Record(false)
+	return resp, nil
+}
+
+// watchBreaker alerts the bot owners whenever the circuit breaker opens or closes.
+func watchBreaker(router SessionRouter) {
+	activision.mu.Lock()
+	activision.onChange = func(open bool) {
+		embed := &discordgo.MessageEmbed{
+			Title:       "Activision API Recovered",
+			Description: fmt.Sprintf("Account checks have resumed on instance %s.", cfg.Cluster.InstanceID),
+			Color:       0x00ff00,
+			Timestamp:   time.Now().Format(time.RFC3339),
+		}
+		if open {
+			embed.Title = "Activision API Unavailable"
+			embed.Description = fmt.Sprintf("Too many requests to Activision failed, so account checks are paused on instance %s. A test request is sent every %s and checks resume once it succeeds.",
+				cfg.Cluster.InstanceID, cfg.Breaker.Cooldown)
+			embed.Color = 0xff0000
+		}
+		go alertOwners(router.SessionForGuild(""), embed)
+	}
+	activision.mu.Unlock()
+}
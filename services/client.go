@@ -0,0 +1,148 @@
+package services
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"codstatusbot2.0/logger"
+)
+
+// requestStatsInterval is how often the request metrics are logged.
+const requestStatsInterval = 5 * time.Minute
+
+// EndpointStats describes the requests sent to one endpoint since the bot started.
+type EndpointStats struct {
+	Endpoint   string
+	Requests   int64
+	Errors     int64         // Requests that got no response at all.
+	Statuses   map[int]int64 // The number of responses per status code.
+	AvgLatency time.Duration
+	MaxLatency time.Duration
+}
+
+var (
+	clientOnce   sync.Once
+	sharedClient *http.Client
+
+	requestStatsMu sync.Mutex
+	requestStats   = make(map[string]*EndpointStats)
+	totalLatency   = make(map[string]time.Duration)
+
+	startHTTPOnce sync.Once
+)
+
+// StartHTTP loads the outbound proxies and starts logging request metrics. It must be
+// called after Configure.
+func StartHTTP() {
+	startHTTPOnce.Do(func() {
+		startProxies()
+		go func() {
+			ticker := time.NewTicker(requestStatsInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				for _, stats := range RequestStats() {
+					logger.Log.WithField("endpoint", stats.Endpoint).Infof("Request stats: requests=%d errors=%d statuses=%v avg_latency=%s max_latency=%s",
+						stats.Requests, stats.Errors, stats.Statuses, stats.AvgLatency, stats.MaxLatency)
+				}
+			}
+		}()
+	})
+}
+
+// httpClient returns the client shared by every request that is not sent through a
+// proxy.
+func httpClient() *http.Client {
+	clientOnce.Do(func() {
+		sharedClient = newClient(newTransport(http.ProxyFromEnvironment))
+	})
+	return sharedClient
+}
+
+// newTransport returns a transport with the configured timeouts and connection pool.
+func newTransport(proxy func(*http.Request) (*url.URL, error)) *http.Transport {
+	return &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   cfg.HTTP.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   cfg.HTTP.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.HTTP.ResponseHeaderTimeout,
+		MaxIdleConns:          cfg.HTTP.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.HTTP.MaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.HTTP.IdleConnTimeout,
+	}
+}
+
+// newClient wraps a transport with the overall request timeout, the response size
+// limit and request metrics.
+func newClient(transport http.RoundTripper) *http.Client {
+	return &http.Client{
+		Transport: instrumentedTransport{next: transport},
+		Timeout:   cfg.HTTP.Timeout,
+	}
+}
+
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+func (t instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	latency := time.Since(start)
+	endpoint := req.URL.Host + req.URL.Path
+
+	status := 0
+	if err == nil {
+		status = resp.StatusCode
+		resp.Body = http.MaxBytesReader(nil, resp.Body, int64(cfg.HTTP.MaxResponseKB)*1024)
+	}
+	recordRequest(endpoint, status, latency)
+	logger.Log.WithField("endpoint", endpoint).Debugf("%s request finished with status %d in %s", req.Method, status, latency)
+	return resp, err
+}
+
+func recordRequest(endpoint string, status int, latency time.Duration) {
+	requestStatsMu.Lock()
+	defer requestStatsMu.Unlock()
+	stats, ok := requestStats[endpoint]
+	if !ok {
+		stats = &EndpointStats{Endpoint: endpoint, Statuses: make(map[int]int64)}
+		requestStats[endpoint] = stats
+	}
+	stats.Requests++
+	if status == 0 {
+		stats.Errors++
+	} else {
+		stats.Statuses[status]++
+	}
+	totalLatency[endpoint] += latency
+	stats.AvgLatency = totalLatency[endpoint] / time.Duration(stats.Requests)
+	if latency > stats.MaxLatency {
+		stats.MaxLatency = latency
+	}
+}
+
+// RequestStats returns the metrics of every endpoint requested so far, sorted by
+// endpoint.
+func RequestStats() []EndpointStats {
+	requestStatsMu.Lock()
+	defer requestStatsMu.Unlock()
+	all := make([]EndpointStats, 0, len(requestStats))
+	for _, stats := range requestStats {
+		copied := *stats
+		copied.Statuses = make(map[int]int64, len(stats.Statuses))
+		for code, count := range stats.Statuses {
+			copied.Statuses[code] = count
+		}
+		all = append(all, copied)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Endpoint < all[j].Endpoint })
+	return all
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// DecodeSSOCookieExpiry reads the expiry embedded in an ACT_SSO_COOKIE value. The
+// cookie is base64 encoded "<user id>:<expiry in milliseconds>:<signature>".
+func DecodeSSOCookieExpiry(ssoCookie string) (time.Time, error) {
+	ssoCookie = strings.TrimSpace(ssoCookie)
+	var decoded []byte
+	var err error
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if decoded, err = encoding.DecodeString(ssoCookie); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cookie is not base64 encoded: %w", err)
+	}
+
+	parts := strings.Split(string(decoded), ":")
+	if len(parts) < 2 {
+		return time.Time{}, errors.New("cookie does not contain an expiry")
+	}
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || expiry <= 0 {
+		return time.Time{}, fmt.Errorf("cookie expiry %q is not a timestamp", parts[1])
+	}
+	// Older cookies store the expiry in seconds rather than milliseconds.
+	if expiry < 1e11 {
+		return time.Unix(expiry, 0), nil
+	}
+	return time.UnixMilli(expiry), nil
+}
+
+// CookieExpiresAt returns the unix expiry of a cookie, or 0 when it cannot be decoded.
+func CookieExpiresAt(ssoCookie string) int64 {
+	expiry, err := DecodeSSOCookieExpiry(ssoCookie)
+	if err != nil {
+		logger.Log.WithError(err).Warn("Could not read the expiry of an SSO cookie")
+		return 0
+	}
+	return expiry.Unix()
+}
+
+// CookieExpiryText describes when a cookie expires, e.g. "expires in 5 days".
+func CookieExpiryText(expiresAt int64, now time.Time) string {
+	if expiresAt == 0 {
+		return "expiry unknown"
+	}
+	remaining := time.Unix(expiresAt, 0).Sub(now)
+	switch {
+	case remaining <= 0:
+		return "expired"
+	case remaining < 24*time.Hour:
+		return fmt.Sprintf("expires <t:%d:R>", expiresAt)
+	default:
+		days := int((remaining + 12*time.Hour) / (24 * time.Hour))
+		if days == 1 {
+			return "expires in 1 day"
+		}
+		return fmt.Sprintf("expires in %d days", days)
+	}
+}
+
+// cookieExpiryTitle describes the time left on a cookie for an embed title, where
+// Discord does not render timestamps.
+func cookieExpiryTitle(expiresAt int64, now time.Time) string {
+	if time.Unix(expiresAt, 0).Sub(now) < 24*time.Hour {
+		return "Expires Within a Day"
+	}
+	// CookieExpiryText reads "expires in N days" once a day or more is left.
+	return "Expires" + strings.TrimPrefix(CookieExpiryText(expiresAt, now), "expires")
+}
+
+// cookieWarningStages returns how long before expiry each reminder is sent, longest
+// first. The first reminder is sent when the configured warning window opens, then
+// again three days and one day before expiry.
+func cookieWarningStages(window time.Duration) []time.Duration {
+	if window <= 0 {
+		return nil
+	}
+	stages := []time.Duration{window}
+	for _, stage := range []time.Duration{3 * 24 * time.Hour, 24 * time.Hour} {
+		if stage < window {
+			stages = append(stages, stage)
+		}
+	}
+	return stages
+}
+
+// cookieWarningStage returns how many reminders should have been sent by now for a
+// cookie expiring at expiresAt.
+func cookieWarningStage(expiresAt int64, now time.Time, stages []time.Duration) int {
+	remaining := time.Unix(expiresAt, 0).Sub(now)
+	stage := 0
+	for _, before := range stages {
+		if remaining <= before {
+			stage++
+		}
+	}
+	return stage
+}
+
+// warnCookieExpiry sends the next reminder for a cookie that is about to expire.
+// Reminders escalate as expiry gets closer and each one is only sent once per cookie.
+// The expiry and reminder level it stores are also set on account, so that a later
+// save of the account does not reset them.
+func warnCookieExpiry(account *models.Account, discord discordapi.Session) {
+	if account.CookieExpiresAt == 0 {
+		account.CookieExpiresAt = CookieExpiresAt(account.SSOCookie)
+		if account.CookieExpiresAt == 0 {
+			return
+		}
+		database.DB.Model(account).Update("cookie_expires_at", account.CookieExpiresAt)
+	}
+
+	now := time.Now()
+	stages := cookieWarningStages(cfg.Cookies.ExpiryWarning)
+	stage := cookieWarningStage(account.CookieExpiresAt, now, stages)
+	if stage <= account.CookieWarningLevel || account.CookieExpiresAt <= now.Unix() {
+		return
+	}
+	if err := database.DB.Model(account).Update("cookie_warning_level", stage).Error; err != nil {
+		logger.Log.WithError(err).Error("Failed to save cookie warning level for account", account.Title)
+		return
+	}
+	account.CookieWarningLevel = stage
+	if isMuted(*account) {
+		logger.Log.Infof("Skipping cookie expiry reminder for muted account %s", account.Title)
+		return
+	}
+
+	color := 0xffff00
+	if stage == len(stages) {
+		color = 0xff0000
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("%s - SSO Cookie %s", account.Title, cookieExpiryTitle(account.CookieExpiresAt, now)),
+		Description: fmt.Sprintf("The SSO cookie for account %s expires <t:%d:R> (<t:%d:f>). Update it with the Update cookie button or /updateaccount before then to keep the account monitored.", account.Title, account.CookieExpiresAt, account.CookieExpiresAt),
+		Color:       color,
+		Timestamp:   now.Format(time.RFC3339),
+	}
+	notifyOwner(*account, discord, "", embed, false)
+}
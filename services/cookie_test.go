@@ -0,0 +1,115 @@
+package services
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestDecodeSSOCookieExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		cookie  string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "milliseconds", cookie: base64.StdEncoding.EncodeToString([]byte("123:1700000000000:sig")), want: time.UnixMilli(1700000000000)},
+		{name: "seconds", cookie: base64.StdEncoding.EncodeToString([]byte("123:1700000000:sig")), want: time.Unix(1700000000, 0)},
+		{name: "unpadded url encoding", cookie: base64.RawURLEncoding.EncodeToString([]byte("123:1700000000000:s?g")), want: time.UnixMilli(1700000000000)},
+		{name: "surrounding whitespace", cookie: " " + base64.StdEncoding.EncodeToString([]byte("123:1700000000000:sig")) + "\n", want: time.UnixMilli(1700000000000)},
+		{name: "not base64", cookie: "not a cookie!", wantErr: true},
+		{name: "no expiry", cookie: base64.StdEncoding.EncodeToString([]byte("123")), wantErr: true},
+		{name: "expiry not a number", cookie: base64.StdEncoding.EncodeToString([]byte("123:soon:sig")), wantErr: true},
+		{name: "zero expiry", cookie: base64.StdEncoding.EncodeToString([]byte("123:0:sig")), wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := DecodeSSOCookieExpiry(test.cookie)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("DecodeSSOCookieExpiry() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeSSOCookieExpiry() error = %v", err)
+			}
+			if !got.Equal(test.want) {
+				t.Errorf("DecodeSSOCookieExpiry() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCookieWarningStages(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		name   string
+		window time.Duration
+		want   []time.Duration
+	}{
+		{name: "disabled", window: 0},
+		{name: "a week", window: 7 * day, want: []time.Duration{7 * day, 3 * day, day}},
+		{name: "three days", window: 3 * day, want: []time.Duration{3 * day, day}},
+		{name: "two days", window: 2 * day, want: []time.Duration{2 * day, day}},
+		{name: "a day", window: day, want: []time.Duration{day}},
+		{name: "twelve hours", window: 12 * time.Hour, want: []time.Duration{12 * time.Hour}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := cookieWarningStages(test.window)
+			if len(got) != len(test.want) {
+				t.Fatalf("cookieWarningStages(%v) = %v, want %v", test.window, got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("cookieWarningStages(%v) = %v, want %v", test.window, got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCookieWarningStage(t *testing.T) {
+	day := 24 * time.Hour
+	now := time.Unix(1700000000, 0)
+	stages := cookieWarningStages(7 * day)
+	tests := []struct {
+		name string
+		left time.Duration
+		want int
+	}{
+		{name: "before the window", left: 7*day + time.Second, want: 0},
+		{name: "window opens", left: 7 * day, want: 1},
+		{name: "just over three days", left: 3*day + time.Second, want: 1},
+		{name: "three days", left: 3 * day, want: 2},
+		{name: "just over a day", left: day + time.Second, want: 2},
+		{name: "a day", left: day, want: 3},
+		{name: "expired", left: -time.Hour, want: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expiresAt := now.Add(test.left).Unix()
+			if got := cookieWarningStage(expiresAt, now, stages); got != test.want {
+				t.Errorf("cookieWarningStage(%v left) = %d, want %d", test.left, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCookieExpiryTitle(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		left time.Duration
+		want string
+	}{
+		{left: 3 * 24 * time.Hour, want: "Expires in 3 days"},
+		{left: 30 * time.Hour, want: "Expires in 1 day"},
+		{left: 20 * time.Hour, want: "Expires Within a Day"},
+	}
+	for _, test := range tests {
+		if got := cookieExpiryTitle(now.Add(test.left).Unix(), now); got != test.want {
+			t.Errorf("cookieExpiryTitle(%v left) = %q, want %q", test.left, got, test.want)
+		}
+	}
+}
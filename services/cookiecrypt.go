@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+
+	"gorm.io/gorm/schema"
+)
+
+// sealedCookiePrefix marks a stored cookie as encrypted. Cookies stored without it are
+// plain text, from before a key was configured.
+const sealedCookiePrefix = "enc:v1:"
+
+func init() {
+	schema.RegisterSerializer("cookie", cookieSerializer{})
+}
+
+// cookieCipher returns the cipher cookies are stored with, or nil when no encryption
+// key is configured.
+func cookieCipher() (cipher.AEAD, []byte, error) {
+	if cfg.Cookies.EncryptionKey == "" {
+		return nil, nil, nil
+	}
+	block, err := aes.NewCipher(deriveCookieKey("encryption"))
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aead, deriveCookieKey("nonce"), nil
+}
+
+// deriveCookieKey derives a separate 32 byte key for each purpose from the configured
+// encryption key.
+func deriveCookieKey(purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(cfg.Cookies.EncryptionKey))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// SealCookie encrypts a cookie for storage. The nonce is derived from the cookie, so
+// the same cookie always seals to the same value and stored cookies can still be
+// compared in queries. Without a configured key the cookie is returned unchanged.
+func SealCookie(cookie string) (string, error) {
+	aead, nonceKey, err := cookieCipher()
+	if err != nil || aead == nil || cookie == "" {
+		return cookie, err
+	}
+	mac := hmac.New(sha256.New, nonceKey)
+	mac.Write([]byte(cookie))
+	nonce := mac.Sum(nil)[:aead.NonceSize()]
+	sealed := aead.Seal(nonce, nonce, []byte(cookie), nil)
+	return sealedCookiePrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenCookie decrypts a cookie sealed by SealCookie. Cookies stored in plain text are
+// returned unchanged.
+func OpenCookie(stored string) (string, error) {
+	if !strings.HasPrefix(stored, sealedCookiePrefix) {
+		return stored, nil
+	}
+	aead, _, err := cookieCipher()
+	if err != nil {
+		return "", err
+	}
+	if aead == nil {
+		return "", errors.New("the SSO cookie is encrypted but COOKIE_ENCRYPTION_KEY is not set")
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(stored, sealedCookiePrefix))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("the stored SSO cookie is malformed")
+	}
+	cookie, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("the stored SSO cookie could not be decrypted, COOKIE_ENCRYPTION_KEY may have changed")
+	}
+	return string(cookie), nil
+}
+
+// cookieSerializer seals Account.SSOCookie when it is written through a model and opens
+// it when it is read. Writes that set the column by name, such as Updates with a map,
+// bypass it and must call SealCookie themselves.
+type cookieSerializer struct{}
+
+func (cookieSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var stored string
+	switch value := dbValue.(type) {
+	case nil:
+	case []byte:
+		stored = string(value)
+	case string:
+		stored = value
+	default:
+		return fmt.Errorf("unsupported SSO cookie value of type %T", dbValue)
+	}
+	cookie, err := OpenCookie(stored)
+	if err != nil {
+		return err
+	}
+	field.ReflectValueOf(ctx, dst).SetString(cookie)
+	return nil
+}
+
+func (cookieSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	cookie, _ := fieldValue.(string)
+	return SealCookie(cookie)
+}
+
+// EncryptStoredCookies seals the cookies still stored in plain text, e.g. after an
+// encryption key was configured for the first time.
+func EncryptStoredCookies() error {
+	if cfg.Cookies.EncryptionKey == "" {
+		logger.Log.Warn("COOKIE_ENCRYPTION_KEY is not set, SSO cookies are stored in plain text")
+		return nil
+	}
+	var accounts []models.Account
+	err := database.DB.Select("id", "sso_cookie").
+		Where("sso_cookie <> ? AND sso_cookie NOT LIKE ?", "", sealedCookiePrefix+"%").
+		Find(&accounts).Error
+	if err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		sealed, err := SealCookie(account.SSOCookie)
+		if err != nil {
+			return err
+		}
+		// Only replace the cookie if it was not changed since it was read.
+		err = database.DB.Model(&account).Where("sso_cookie = ?", account.SSOCookie).Update("sso_cookie", sealed).Error
+		if err != nil {
+			return err
+		}
+	}
+	if len(accounts) > 0 {
+		logger.Log.Infof("Encrypted the SSO cookies of %d account(s)", len(accounts))
+	}
+	return nil
+}
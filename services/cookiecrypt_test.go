@@ -0,0 +1,82 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"codstatusbot2.0/config"
+)
+
+func useCookieKey(t *testing.T, key string) {
+	t.Helper()
+	c := config.Default()
+	c.Cookies.EncryptionKey = key
+	Configure(c)
+	t.Cleanup(func() { Configure(config.Default()) })
+}
+
+func TestSealCookie(t *testing.T) {
+	useCookieKey(t, strings.Repeat("k", 32))
+
+	sealed, err := SealCookie("cookie")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, sealedCookiePrefix) || strings.Contains(sealed, "cookie") {
+		t.Fatalf("SealCookie() = %q, want an encrypted value", sealed)
+	}
+	// Stored cookies are compared in queries, so sealing must be deterministic.
+	if again, _ := SealCookie("cookie"); again != sealed {
+		t.Errorf("SealCookie() = %q then %q, want the same value", sealed, again)
+	}
+	if other, _ := SealCookie("other"); other == sealed {
+		t.Errorf("SealCookie() sealed two cookies to %q", sealed)
+	}
+	if opened, err := OpenCookie(sealed); err != nil || opened != "cookie" {
+		t.Errorf("OpenCookie() = %q, %v, want %q", opened, err, "cookie")
+	}
+}
+
+func TestOpenCookie(t *testing.T) {
+	useCookieKey(t, strings.Repeat("k", 32))
+	sealed, _ := SealCookie("cookie")
+
+	tests := []struct {
+		name    string
+		key     string
+		stored  string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain text", key: strings.Repeat("k", 32), stored: "cookie", want: "cookie"},
+		{name: "plain text without a key", stored: "cookie", want: "cookie"},
+		{name: "empty", key: strings.Repeat("k", 32), stored: "", want: ""},
+		{name: "sealed", key: strings.Repeat("k", 32), stored: sealed, want: "cookie"},
+		{name: "sealed without a key", stored: sealed, wantErr: true},
+		{name: "sealed with another key", key: strings.Repeat("x", 32), stored: sealed, wantErr: true},
+		{name: "tampered", key: strings.Repeat("k", 32), stored: sealed[:len(sealed)-2] + "AA", wantErr: true},
+		{name: "malformed", key: strings.Repeat("k", 32), stored: sealedCookiePrefix + "!", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useCookieKey(t, test.key)
+			got, err := OpenCookie(test.stored)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("OpenCookie() = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != test.want {
+				t.Errorf("OpenCookie() = %q, %v, want %q", got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestSealCookieWithoutKey(t *testing.T) {
+	useCookieKey(t, "")
+	if sealed, err := SealCookie("cookie"); err != nil || sealed != "cookie" {
+		t.Errorf("SealCookie() = %q, %v, want the cookie unchanged", sealed, err)
+	}
+}
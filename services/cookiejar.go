@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"sync"
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// ssoCookieName is the cookie Activision authenticates requests with.
+	ssoCookieName = "ACT_SSO_COOKIE"
+
+	AuditCookieRotated = "cookie_rotated"
+)
+
+// errCookieChanged is returned when the stored cookie changed while a request that
+// rotated it was in flight, e.g. because the owner updated it.
+var errCookieChanged = errors.New("stored cookie changed in the meantime")
+
+// rotatedCookies holds, per account ID, the cookie rotation that happened during a
+// check so that the check does not save the old cookie back.
+var rotatedCookies sync.Map
+
+type cookieRotation struct {
+	from, to string
+}
+
+// sendWithJar sends a request with a cookie jar for the duration of the request, so
+// that cookies set on redirects are followed too, and stores the account's cookie if
+// Activision rotated it. The jar is per request, the connections are shared.
+func sendWithJar(client *http.Client, req *http.Request, creds Credentials) (*http.Response, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	withJar := *client
+	withJar.Jar = jar
+	resp, err := withJar.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if cookie := rotatedCookie(jar, req, resp); cookie != "" && cookie != creds.SSOCookie {
+		storeRotatedCookie(creds, cookie)
+	}
+	return resp, nil
+}
+
+// rotatedCookie returns the ACT_SSO_COOKIE set by the response, or by a redirect on
+// the way to it, or "" if none was set.
+func rotatedCookie(jar http.CookieJar, req *http.Request, resp *http.Response) string {
+	urls := []*http.Request{req}
+	if resp.Request != nil && resp.Request.URL.Host != req.URL.Host {
+		urls = append(urls, resp.Request)
+	}
+	for _, r := range urls {
+		for _, cookie := range jar.Cookies(r.URL) {
+			if cookie.Name == ssoCookieName && cookie.Value != "" {
+				return cookie.Value
+			}
+		}
+	}
+	return ""
+}
+
+// storeRotatedCookie replaces the stored cookie of the account with the one Activision
+// rotated it to and records it in the audit log, in one transaction. Nothing is stored
+// for cookies that do not belong to a stored account yet or that were changed in the
+// meantime.
+func storeRotatedCookie(creds Credentials, cookie string) {
+	if creds.AccountID == 0 {
+		return
+	}
+	expiresAt := CookieExpiresAt(cookie)
+	sealedFrom, err := SealCookie(creds.SSOCookie)
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to encrypt the SSO cookie of account %d", creds.AccountID)
+		return
+	}
+	sealedTo, err := SealCookie(cookie)
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to encrypt the SSO cookie of account %d", creds.AccountID)
+		return
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// The stored cookie may still be in plain text if it was saved before a key was set.
+		result := tx.Model(&models.Account{}).
+			Where("id = ? AND sso_cookie IN ?", creds.AccountID, []string{creds.SSOCookie, sealedFrom}).
+			Updates(map[string]interface{}{
+				"sso_cookie":           sealedTo,
+				"cookie_expires_at":    expiresAt,
+				"cookie_warning_level": 0,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errCookieChanged
+		}
+		detail := "Activision refreshed the SSO cookie"
+		if expiresAt > 0 {
+			detail += fmt.Sprintf(", it now expires %s", time.Unix(expiresAt, 0).UTC().Format(time.RFC1123))
+		}
+		return tx.Create(&models.AuditLog{AccountID: creds.AccountID, Action: AuditCookieRotated, Detail: detail}).Error
+	})
+	if errors.Is(err, errCookieChanged) {
+		logger.Log.Infof("Ignoring rotated cookie for account %d, its cookie was changed in the meantime", creds.AccountID)
+		return
+	}
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to store rotated cookie for account %d", creds.AccountID)
+		return
+	}
+	logger.Log.Infof("Stored rotated SSO cookie for account %d", creds.AccountID)
+	rotatedCookies.Store(creds.AccountID, cookieRotation{from: creds.SSOCookie, to: cookie})
+}
+
+// applyRotatedCookie updates an account loaded before its cookie was rotated, so that
+// saving it does not bring back the old cookie.
+func applyRotatedCookie(account *models.Account) {
+	value, ok := rotatedCookies.LoadAndDelete(account.ID)
+	if !ok || value.(cookieRotation).from != account.SSOCookie {
+		return
+	}
+	account.SSOCookie = value.(cookieRotation).to
+	account.CookieExpiresAt = CookieExpiresAt(account.SSOCookie)
+	account.CookieWarningLevel = 0
+}
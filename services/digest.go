@@ -0,0 +1,328 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+	DigestOff    = "off"
+
+	// digestFieldsPerEmbed keeps each embed well below Discord's 25 field and
+	// 6000 character limits.
+	digestFieldsPerEmbed = 20
+
+	// Discord allows at most 10 embeds per message, with 6000 characters between them.
+	maxEmbedsPerMessage = 10
+	maxEmbedCharacters  = 6000
+
+	// maxDigestChanges is how many status changes an account lists before the rest are
+	// summed up, so an account that flapped all week still fits in one field.
+	maxDigestChanges = 3
+
+	// Discord's limits for an embed field's name and value.
+	maxFieldName  = 256
+	maxFieldValue = 1024
+)
+
+// DigestSchedule returns the user's digest schedule, falling back to the configured
+// default for users who have not picked one.
+func DigestSchedule(settings models.UserSettings) string {
+	if settings.DigestSchedule != "" {
+		return settings.DigestSchedule
+	}
+	return cfg.Digest.DefaultSchedule
+}
+
+// DigestPeriod returns the time between digests for a schedule, or 0 when digests
+// are turned off.
+func DigestPeriod(schedule string) time.Duration {
+	switch schedule {
+	case DigestDaily:
+		return 24 * time.Hour
+	case DigestWeekly:
+		return 7 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// SendDigests sends a digest to every user whose digest is due. It runs on a single
+// instance through RunSingleton.
+func SendDigests(router SessionRouter) {
+	var userIDs []string
+	if err := database.DB.Model(&models.Account{}).Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		logger.Log.WithError(err).Error("Failed to fetch users for digests")
+		return
+	}
+
+	now := time.Now()
+	for _, userID := range userIDs {
+		settings := GetUserSettings(userID)
+		period := DigestPeriod(DigestSchedule(settings))
+		if period == 0 || settings.NextDigestAt > now.Unix() {
+			continue
+		}
+
+		if settings.NextDigestAt == 0 {
+			// Start the schedule for users who have never had a digest rather than
+			// sending every user one at once. The first digest then only lists the
+			// changes from here on instead of the whole history.
+			if settings.LastDigestAt == 0 {
+				settings.LastDigestAt = now.Unix()
+			}
+			settings.NextDigestAt = now.Add(period).Unix()
+		} else if quietEnd, quiet := quietUntil(settings, now); quiet {
+			settings.NextDigestAt = quietEnd.Unix()
+		} else {
+			if settings.LastDigestAt == 0 {
+				settings.LastDigestAt = now.Add(-period).Unix()
+			}
+			sendDigest(userID, settings, router, now)
+			settings.LastDigestAt = now.Unix()
+			settings.NextDigestAt = now.Add(period).Unix()
+		}
+		if err := database.DB.Save(&settings).Error; err != nil {
+			logger.Log.WithError(err).Errorf("Failed to save digest schedule for user %s", userID)
+		}
+	}
+}
+
+// digestDestination identifies where a group of accounts is reported: a DM or a
+// guild channel, depending on each account's notification settings.
+type digestDestination struct {
+	guildID   string
+	channelID string
+}
+
+func sendDigest(userID string, settings models.UserSettings, router SessionRouter, now time.Time) {
+	var accounts []models.Account
+	if err := database.DB.Where("user_id = ?", userID).Order("title").Find(&accounts).Error; err != nil {
+		logger.Log.WithError(err).Errorf("Failed to fetch accounts for digest of user %s", userID)
+		return
+	}
+
+	groups := make(map[digestDestination][]models.Account)
+	var order []digestDestination
+	guilds := make(map[string]models.GuildSettings)
+	for _, account := range accounts {
+		if isMuted(account) || account.PausedUntil > now.Unix() {
+			continue
+		}
+		destination := digestDestination{}
+		if account.NotificationType != "dm" {
+			guild, ok := guilds[account.GuildID]
+			if !ok {
+				guild = GetGuildSettings(account.GuildID)
+				guilds[account.GuildID] = guild
+			}
+			destination.guildID = account.GuildID
+			destination.channelID = account.ChannelID
+			if guild.AlertsChannelID != "" {
+				destination.channelID = guild.AlertsChannelID
+			}
+		}
+		if _, ok := groups[destination]; !ok {
+			order = append(order, destination)
+		}
+		groups[destination] = append(groups[destination], account)
+	}
+
+	title := "Daily Digest"
+	if DigestSchedule(settings) == DigestWeekly {
+		title = "Weekly Digest"
+	}
+	for _, destination := range order {
+		group := groups[destination]
+		discord := router.SessionForGuild(destination.guildID)
+		channelID := destination.channelID
+		content := fmt.Sprintf("<@%s>", userID)
+		if destination.channelID == "" {
+			channel, err := discord.UserChannelCreate(userID)
+			if err != nil {
+				logger.Log.WithError(err).Error("Failed to create DM channel")
+				continue
+			}
+			channelID = channel.ID
+			content = ""
+		}
+
+		for _, embeds := range splitEmbeds(BuildDigest(title, group, digestChanges(group, settings.LastDigestAt), now)) {
+			_, err := discord.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+				Content: content,
+				Embeds:  embeds,
+			})
+			content = ""
+			if err != nil {
+				logger.Log.WithError(err).Errorf("Failed to send digest to user %s", userID)
+				break
+			}
+		}
+
+		ids := make([]uint, len(group))
+		for i, account := range group {
+			ids[i] = account.ID
+		}
+		database.DB.Model(&models.Account{}).Where("id IN ?", ids).Update("last_notification", now.Unix())
+	}
+}
+
+// digestChanges returns the status changes recorded since the last digest, keyed by
+// account ID and oldest first.
+func digestChanges(accounts []models.Account, since int64) map[uint][]models.Ban {
+	ids := make([]uint, len(accounts))
+	for i, account := range accounts {
+		ids[i] = account.ID
+	}
+	var bans []models.Ban
+	database.DB.Where("account_id IN ? AND created_at > ?", ids, time.Unix(since, 0)).Order("created_at").Find(&bans)
+
+	changes := make(map[uint][]models.Ban)
+	for _, ban := range bans {
+		changes[ban.AccountID] = append(changes[ban.AccountID], ban)
+	}
+	return changes
+}
+
+// BuildDigest renders the digest for a group of accounts as one or more embeds, each
+// with one field per account.
+func BuildDigest(title string, accounts []models.Account, changes map[uint][]models.Ban, now time.Time) []*discordgo.MessageEmbed {
+	counts := make(map[models.Status]int)
+	cookieProblems := 0
+	color := GetColorForStatus(models.StatusGood, false)
+	for _, account := range accounts {
+		counts[account.LastStatus]++
+		if account.IsExpiredCookie {
+			cookieProblems++
+		}
+		if severity(account) > severityOfColor(color) {
+			color = GetColorForStatus(account.LastStatus, account.IsExpiredCookie)
+		}
+	}
+
+	summary := fmt.Sprintf("%d account(s): %d good, %d shadowbanned, %d permanently banned",
+		len(accounts), counts[models.StatusGood], counts[models.StatusShadowban], counts[models.StatusPermaban])
+	if cookieProblems > 0 {
+		summary += fmt.Sprintf(", %d with an expired cookie", cookieProblems)
+	}
+
+	var embeds []*discordgo.MessageEmbed
+	for start := 0; start < len(accounts); start += digestFieldsPerEmbed {
+		end := start + digestFieldsPerEmbed
+		if end > len(accounts) {
+			end = len(accounts)
+		}
+		embed := &discordgo.MessageEmbed{
+			Title:     title,
+			Color:     color,
+			Timestamp: now.Format(time.RFC3339),
+		}
+		if start == 0 {
+			embed.Description = summary
+		} else {
+			embed.Title = fmt.Sprintf("%s (continued)", title)
+		}
+		for _, account := range accounts[start:end] {
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name:  truncate(fmt.Sprintf("%s - %s", account.Title, account.LastStatus), maxFieldName),
+				Value: truncate(digestLine(account, changes[account.ID]), maxFieldValue),
+			})
+		}
+		embeds = append(embeds, embed)
+	}
+	return embeds
+}
+
+// splitEmbeds groups embeds into as few messages as Discord's per-message embed limits
+// allow, keeping their order.
+func splitEmbeds(embeds []*discordgo.MessageEmbed) [][]*discordgo.MessageEmbed {
+	var messages [][]*discordgo.MessageEmbed
+	var current []*discordgo.MessageEmbed
+	size := 0
+	for _, embed := range embeds {
+		length := embedLength(embed)
+		if len(current) > 0 && (len(current) == maxEmbedsPerMessage || size+length > maxEmbedCharacters) {
+			messages = append(messages, current)
+			current, size = nil, 0
+		}
+		current = append(current, embed)
+		size += length
+	}
+	if len(current) > 0 {
+		messages = append(messages, current)
+	}
+	return messages
+}
+
+// embedLength counts the characters Discord counts towards the 6000 character limit.
+func embedLength(embed *discordgo.MessageEmbed) int {
+	length := utf8.RuneCountInString(embed.Title) + utf8.RuneCountInString(embed.Description)
+	for _, field := range embed.Fields {
+		length += utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+	}
+	if embed.Footer != nil {
+		length += utf8.RuneCountInString(embed.Footer.Text)
+	}
+	if embed.Author != nil {
+		length += utf8.RuneCountInString(embed.Author.Name)
+	}
+	return length
+}
+
+func digestLine(account models.Account, changes []models.Ban) string {
+	var lines []string
+	if len(changes) > maxDigestChanges {
+		lines = append(lines, fmt.Sprintf("…and %d earlier change(s)", len(changes)-maxDigestChanges))
+		changes = changes[len(changes)-maxDigestChanges:]
+	}
+	for _, change := range changes {
+		lines = append(lines, fmt.Sprintf("Changed to %s <t:%d:R>", change.Status, change.CreatedAt.Unix()))
+	}
+	if account.IsExpiredCookie {
+		lines = append(lines, "Cookie expired, use /updateaccount")
+	} else if account.CookieExpiresAt > 0 && time.Until(time.Unix(account.CookieExpiresAt, 0)) < cfg.Cookies.ExpiryWarning {
+		lines = append(lines, "Cookie "+CookieExpiryText(account.CookieExpiresAt, time.Now()))
+	}
+	if account.LastCheck > 0 {
+		lines = append(lines, fmt.Sprintf("Last checked <t:%d:R>", account.LastCheck))
+	} else {
+		lines = append(lines, "Not checked yet")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// truncate shortens text to at most length characters, marking the cut with an
+// ellipsis.
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length-1]) + "…"
+}
+
+// severity orders accounts so the digest takes the color of the worst one.
+func severity(account models.Account) int {
+	return severityOfColor(GetColorForStatus(account.LastStatus, account.IsExpiredCookie))
+}
+
+func severityOfColor(color int) int {
+	switch color {
+	case 0xff0000:
+		return 2
+	case 0xffff00:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"codstatusbot2.0/config"
+	"codstatusbot2.0/models"
+)
+
+func TestDigestLineCapsChanges(t *testing.T) {
+	Configure(config.Default())
+	tests := []struct {
+		name        string
+		changes     int
+		wantChanges int
+		wantMore    string
+	}{
+		{name: "none", changes: 0, wantChanges: 0},
+		{name: "at the cap", changes: maxDigestChanges, wantChanges: maxDigestChanges},
+		{name: "over the cap", changes: maxDigestChanges + 5, wantChanges: maxDigestChanges, wantMore: "…and 5 earlier change(s)"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var changes []models.Ban
+			for i := 0; i < test.changes; i++ {
+				ban := models.Ban{Status: models.StatusShadowban}
+				ban.CreatedAt = time.Unix(int64(1000+i), 0)
+				changes = append(changes, ban)
+			}
+			line := digestLine(models.Account{LastCheck: 2000}, changes)
+			if got := strings.Count(line, "Changed to"); got != test.wantChanges {
+				t.Errorf("listed %d changes, want %d:\n%s", got, test.wantChanges, line)
+			}
+			if test.wantMore != "" && !strings.HasPrefix(line, test.wantMore) {
+				t.Errorf("line = %q, want it to start with %q", line, test.wantMore)
+			}
+			if latest := fmt.Sprintf("<t:%d:R>", 1000+test.changes-1); test.changes > 0 && !strings.Contains(line, latest) {
+				t.Errorf("line = %q, want the latest change listed", line)
+			}
+		})
+	}
+}
+
+func TestBuildDigestFieldLimits(t *testing.T) {
+	Configure(config.Default())
+	account := models.Account{Title: strings.Repeat("a", 300), LastStatus: models.StatusGood}
+	embeds := BuildDigest("Daily digest", []models.Account{account}, nil, time.Now())
+	field := embeds[0].Fields[0]
+	if n := utf8.RuneCountInString(field.Name); n > maxFieldName {
+		t.Errorf("field name has %d characters, want at most %d", n, maxFieldName)
+	}
+	if n := utf8.RuneCountInString(field.Value); n > maxFieldValue {
+		t.Errorf("field value has %d characters, want at most %d", n, maxFieldValue)
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Indefinitely is stored in PausedUntil or MutedUntil when there is no end time.
+const Indefinitely int64 = math.MaxInt64
+
+// ParseUntil turns a user supplied duration such as "90m", "12h", "3d", "2w",
+// "indefinitely" or "off" into the timestamp it lasts until. "off" returns 0.
+func ParseUntil(input string, now time.Time) (int64, error) {
+	input = strings.ToLower(strings.TrimSpace(input))
+	switch input {
+	case "off", "resume", "none", "0":
+		return 0, nil
+	case "indefinitely", "forever", "always":
+		return Indefinitely, nil
+	}
+	if len(input) < 2 {
+		return 0, errors.New("invalid duration")
+	}
+
+	unit := time.Duration(0)
+	switch input[len(input)-1] {
+	case 'm':
+		unit = time.Minute
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	case 'w':
+		unit = 7 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("unknown duration unit in %q", input)
+	}
+	amount, err := strconv.Atoi(input[:len(input)-1])
+	if err != nil || amount <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", input)
+	}
+	if time.Duration(amount) > 365*24*time.Hour/unit {
+		return 0, fmt.Errorf("duration %q is longer than a year, use indefinitely instead", input)
+	}
+	return now.Add(time.Duration(amount) * unit).Unix(), nil
+}
+
+// FormatUntil describes a PausedUntil or MutedUntil timestamp for a Discord message.
+func FormatUntil(until int64) string {
+	if until == Indefinitely {
+		return "indefinitely"
+	}
+	return fmt.Sprintf("until <t:%d:f>", until)
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"strings"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/models"
+)
+
+// GetGuildSettings returns the settings for a guild, or the defaults if an admin has
+// never configured it.
+func GetGuildSettings(guildID string) models.GuildSettings {
+	settings := models.GuildSettings{GuildID: guildID, DefaultNotificationType: "channel"}
+	if guildID == "" {
+		return settings
+	}
+	database.DB.Where("guild_id = ?", guildID).First(&settings)
+	return settings
+}
+
+// AllowedRoles splits the comma separated role list stored on the settings.
+func AllowedRoles(settings models.GuildSettings) []string {
+	if settings.AllowedRoleIDs == "" {
+		return nil
+	}
+	return strings.Split(settings.AllowedRoleIDs, ",")
+}
+
+// MemberAllowed reports whether a member with the given roles may use the bot in a guild.
+func MemberAllowed(guildID string, memberRoles []string) bool {
+	allowed := AllowedRoles(GetGuildSettings(guildID))
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, role := range memberRoles {
+		for _, allowedRole := range allowed {
+			if role == allowedRole {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// notificationChannel returns the channel a notification about the account should be
+// sent to: the owner's DMs, the guild's alerts channel, or the channel it was added from.
+func notificationChannel(account models.Account, discord discordapi.Session) (string, error) {
+	if account.NotificationType == "dm" {
+		channel, err := discord.UserChannelCreate(account.UserID)
+		if err != nil {
+			return "", err
+		}
+		return channel.ID, nil
+	}
+	if settings := GetGuildSettings(account.GuildID); settings.AlertsChannelID != "" {
+		return settings.AlertsChannelID, nil
+	}
+	return account.ChannelID, nil
+}
+
+func isBanStatus(status models.Status) bool {
+	return status == models.StatusPermaban || status == models.StatusShadowban
+}
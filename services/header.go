@@ -2,19 +2,194 @@ package services
 
 import (
 	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"codstatusbot2.0/config"
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+)
+
+const (
+	HeaderRotationNone       = "none"
+	HeaderRotationRoundRobin = "round_robin"
+	HeaderRotationSticky     = "sticky"
 )
 
-func GenerateHeaders(ssoCookie string) map[string]string {
-	return map[string]string{
-		"accept":         "*/*",
-		"sec-fetch-mode": "cors",
-		"cookie":         fmt.Sprintf("ACT_SSO_COOKIE=%s", ssoCookie),
+// defaultHeaderProfiles are used when the configuration does not define any profile.
+var defaultHeaderProfiles = []config.HeaderProfile{
+	{
+		Name:            "chrome-windows",
+		UserAgent:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+		AcceptLanguage:  "en-US,en;q=0.9",
+		Referer:         "https://support.activision.com/",
+		SecChUa:         `"Not/A)Brand";v="8", "Chromium";v="126", "Google Chrome";v="126"`,
+		SecChUaPlatform: `"Windows"`,
+		SecChUaMobile:   "?0",
+	},
+	{
+		Name:           "firefox-windows",
+		UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:127.0) Gecko/20100101 Firefox/127.0",
+		AcceptLanguage: "en-US,en;q=0.5",
+		Referer:        "https://support.activision.com/",
+	},
+}
+
+var nextHeaderProfile atomic.Uint64
+
+// Credentials identify the Activision account a request is sent for.
+type Credentials struct {
+	AccountID     uint   // The ID of the stored account, 0 for a cookie that is not stored yet.
+	SSOCookie     string // The account's ACT_SSO_COOKIE.
+	HeaderProfile string // The header profile assigned to the account, empty to use the configured default or rotation.
+}
+
+// AccountCredentials returns the credentials of a stored account.
+func AccountCredentials(account models.Account) Credentials {
+	return Credentials{AccountID: account.ID, SSOCookie: account.SSOCookie, HeaderProfile: account.HeaderProfile}
+}
+
+// key identifies the account for sticky proxy and header profile assignment.
+func (c Credentials) key() string {
+	if c.AccountID != 0 {
+		return fmt.Sprintf("account:%d", c.AccountID)
 	}
+	return c.SSOCookie
 }
 
-func GeneratePostHeaders(ssoCookie string) map[string]string {
-	headers := GenerateHeaders(ssoCookie)
-	headers["Content-Type"] = "application/x-www-form-urlencoded"
-	headers["x-requested-with"] = "XMLHttpRequest"
-	return headers
+func headerProfiles() []config.HeaderProfile {
+	if len(cfg.Requests.HeaderProfiles) > 0 {
+		return cfg.Requests.HeaderProfiles
+	}
+	return defaultHeaderProfiles
+}
+
+// HeaderProfileNames returns the names of the header profiles that can be assigned to
+// an account.
+func HeaderProfileNames() []string {
+	profiles := headerProfiles()
+	names := make([]string, len(profiles))
+	for i, profile := range profiles {
+		names[i] = profile.Name
+	}
+	return names
+}
+
+// HeaderProfileName returns the name of the configured header profile called name,
+// compared without regard to case, so it can be assigned to an account.
+func HeaderProfileName(name string) (string, error) {
+	var names []string
+	for _, profile := range headerProfiles() {
+		if strings.EqualFold(profile.Name, name) {
+			return profile.Name, nil
+		}
+		names = append(names, profile.Name)
+	}
+	return "", fmt.Errorf("no header profile named %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// headerProfileFor returns the profile assigned to the account or, if it has none,
+// the one picked by the configured rotation.
+func headerProfileFor(creds Credentials) config.HeaderProfile {
+	profiles := headerProfiles()
+	name := creds.HeaderProfile
+	if name == "" {
+		switch cfg.Requests.HeaderRotation {
+		case HeaderRotationRoundRobin:
+			if name = assignHeaderProfile(creds, profiles); name == "" {
+				return profiles[nextHeaderProfile.Add(1)%uint64(len(profiles))]
+			}
+		case HeaderRotationSticky:
+			h := fnv.New64a()
+			h.Write([]byte(creds.key()))
+			return profiles[h.Sum64()%uint64(len(profiles))]
+		}
+		if name == "" {
+			name = cfg.Requests.DefaultHeaderProfile
+		}
+	}
+	for _, profile := range profiles {
+		if strings.EqualFold(profile.Name, name) {
+			return profile
+		}
+	}
+	if name != "" {
+		logger.Log.Warnf("Unknown header profile %q, using %q", name, profiles[0].Name)
+	}
+	return profiles[0]
+}
+
+// assignHeaderProfile gives a stored account without a profile the next one in turn and
+// saves it, so round_robin rotation spreads accounts over the profiles while each
+// account keeps sending the same headers. The account is read again first because the
+// credentials may predate an assignment made by an earlier request. It returns an
+// empty string for a cookie that is not stored yet.
+func assignHeaderProfile(creds Credentials, profiles []config.HeaderProfile) string {
+	if creds.AccountID == 0 {
+		return ""
+	}
+	var account models.Account
+	if err := database.DB.Select("id", "header_profile").First(&account, creds.AccountID).Error; err != nil {
+		logger.Log.WithError(err).Errorf("Failed to read the header profile of account %d", creds.AccountID)
+		return ""
+	}
+	if account.HeaderProfile != "" {
+		return account.HeaderProfile
+	}
+
+	name := profiles[nextHeaderProfile.Add(1)%uint64(len(profiles))].Name
+	result := database.DB.Model(&account).Where("header_profile = ?", "").Update("header_profile", name)
+	if result.Error != nil {
+		logger.Log.WithError(result.Error).Errorf("Failed to save the header profile of account %d", creds.AccountID)
+		return name
+	}
+	if result.RowsAffected == 0 {
+		// Another request assigned a profile first.
+		database.DB.Select("id", "header_profile").First(&account, creds.AccountID)
+		if account.HeaderProfile != "" {
+			return account.HeaderProfile
+		}
+	}
+	logger.Log.Infof("Assigned header profile %q to account %d", name, creds.AccountID)
+	return name
+}
+
+// newActivisionRequest builds every request sent to Activision, applying the account's
+// header profile and cookie. Requests without a cookie, such as health checks, are
+// sent unauthenticated. A non-nil body is sent as a form post.
+func newActivisionRequest(method, target string, creds Credentials, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	profile := headerProfileFor(creds)
+	req.Header.Set("accept", "*/*")
+	req.Header.Set("sec-fetch-mode", "cors")
+	if creds.SSOCookie != "" {
+		req.Header.Set("cookie", fmt.Sprintf("ACT_SSO_COOKIE=%s", creds.SSOCookie))
+	}
+	setHeader(req, "User-Agent", profile.UserAgent)
+	setHeader(req, "Accept-Language", profile.AcceptLanguage)
+	setHeader(req, "Referer", profile.Referer)
+	setHeader(req, "sec-ch-ua", profile.SecChUa)
+	setHeader(req, "sec-ch-ua-platform", profile.SecChUaPlatform)
+	setHeader(req, "sec-ch-ua-mobile", profile.SecChUaMobile)
+	for name, value := range profile.Headers {
+		req.Header.Set(name, value)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("x-requested-with", "XMLHttpRequest")
+	}
+	return req, nil
+}
+
+func setHeader(req *http.Request, name, value string) {
+	if value != "" {
+		req.Header.Set(name, value)
+	}
 }
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"codstatusbot2.0/config"
+	"codstatusbot2.0/database/fakedb"
+)
+
+func TestHeaderProfileForRoundRobin(t *testing.T) {
+	c := config.Default()
+	c.Requests.HeaderRotation = HeaderRotationRoundRobin
+	Configure(c)
+	t.Cleanup(func() { Configure(config.Default()) })
+
+	tests := []struct {
+		name     string
+		creds    Credentials
+		stored   string // The profile stored for the account.
+		want     string // The profile used, empty if any may be picked.
+		wantSave bool
+	}{
+		{name: "assigned", creds: Credentials{AccountID: 1, HeaderProfile: "firefox-windows"}, want: "firefox-windows"},
+		{name: "assigned by an earlier request", creds: Credentials{AccountID: 1}, stored: "firefox-windows", want: "firefox-windows"},
+		{name: "not assigned yet", creds: Credentials{AccountID: 1}, wantSave: true},
+		{name: "not stored", creds: Credentials{SSOCookie: "cookie"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := fakedb.Use(t)
+			db.Return("accounts", map[string]driver.Value{"id": int64(1), "header_profile": test.stored})
+
+			profile := headerProfileFor(test.creds)
+			if test.want != "" && profile.Name != test.want {
+				t.Errorf("headerProfileFor() = %q, want %q", profile.Name, test.want)
+			}
+			saved := false
+			for _, exec := range db.Execs() {
+				saved = saved || (strings.HasPrefix(exec.Query, "UPDATE `accounts`") && strings.Contains(exec.Query, "`header_profile`"))
+			}
+			if saved != test.wantSave {
+				t.Errorf("profile saved = %v, want %v", saved, test.wantSave)
+			}
+		})
+	}
+}
@@ -1,71 +1,71 @@
 package services
 
 import (
+	"bytes"
 	"codstatusbot2.0/logger"
 	"codstatusbot2.0/models"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
 var url1 = "https://support.activision.com/api/bans/appeal?locale=en"
-var url2 = "https://support.activision.com/api/profile?accts=false"
+var url2 = "https://support.activision.com/api/profile?accts=true"
 
-// var url3 = "https://profile.callofduty.com/promotions/redeemCode/"
+var url3 = "https://profile.callofduty.com/promotions/redeemCode/"
 
-/*
-func ClaimSingleReward(ssoCookie, code string) (string, error) {
+// ClaimSingleReward redeems a reward code for the account the cookie belongs to. An
+// error means the attempt could not be made; a rejected code is reported in the outcome.
+func ClaimSingleReward(creds Credentials, code string) (ClaimOutcome, error) {
 	logger.Log.Info("Starting ClaimSingleReward function")
-	req, err := http.NewRequest("POST", url3, strings.NewReader(fmt.Sprintf("code=%s", code)))
+	form := url.Values{"code": {code}}
+	req, err := newActivisionRequest("POST", url3, creds, strings.NewReader(form.Encode()))
 	if err != nil {
-		return "", fmt.Errorf("failed to create HTTP request to claim reward: %w", err)
+		return ClaimOutcome{}, fmt.Errorf("failed to create HTTP request to claim reward: %w", err)
 	}
-	headers := GeneratePostHeaders(ssoCookie)
-	for k, v := range headers {
-		req.Header.Set(k, v)
-	}
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Do(req)
+	resp, err := sendThroughProxy(req, creds)
 	if err != nil {
-		return "", fmt.Errorf("failed to send HTTP request to claim reward: %w", err)
+		return ClaimOutcome{}, fmt.Errorf("failed to send HTTP request to claim reward: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
+		return ClaimOutcome{}, fmt.Errorf("failed to read response body: %w", err)
 	}
-	if strings.Contains(string(body), "redemption-success") {
-		start := strings.Index(string(body), "Just Unlocked:<br><br><div class=\"accent-highlight mw2\">")
-		end := strings.Index(string(body), "</div></h4>")
-		if start != -1 && end != -1 {
-			unlockedItem := strings.TrimSpace(string(body)[start+len("Just Unlocked:<br><br><div class=\"accent-highlight mw2\">") : end])
-			return fmt.Sprintf("Successfully claimed reward: %s", unlockedItem), nil
-		}
-		return "Successfully claimed reward, but couldn't extract details", nil
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return ClaimOutcome{}, fmt.Errorf("reward service returned status %d", resp.StatusCode)
+	}
+	outcome := ParseRedemptionResponse(body)
+	if outcome.Result == ClaimFailed {
+		logger.Log.Infof("Unexpected reward claim response (status %d): %s", resp.StatusCode, truncateBody(body))
+	}
+	return outcome, nil
+}
+
+// truncateBody shortens a response body for logging.
+func truncateBody(body []byte) string {
+	const limit = 500
+	if len(body) > limit {
+		return string(body[:limit]) + "..."
 	}
-	logger.Log.Infof("Unexpected response body: %s", string(body))
-	return "", fmt.Errorf("failed to claim reward: unexpected response")
+	return string(body)
 }
-*/
 
 func VerifySSOCookie(ssoCookie string) bool {
-	logger.Log.Infof("Verifying SSO cookie: %s ", ssoCookie)
-	req, err := http.NewRequest("GET", url2, nil)
+	logger.Log.Info("Verifying SSO cookie")
+	creds := Credentials{SSOCookie: ssoCookie}
+	req, err := newActivisionRequest("GET", url2, creds, nil)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error creating verification request")
 		return false
 	}
-	headers := GenerateHeaders(ssoCookie)
-	for k, v := range headers {
-		req.Header.Set(k, v)
-	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := sendThroughProxy(req, creds)
 	if err != nil {
 		logger.Log.WithError(err).Error("Error sending verification request")
 		return false
@@ -87,25 +87,60 @@ func VerifySSOCookie(ssoCookie string) bool {
 	return true
 }
 
-func CheckAccount(ssoCookie string) (models.Status, error) {
+// cookieRejected asks the profile API whether it still accepts the cookie. Unlike
+// VerifySSOCookie it goes through the circuit breaker and returns an error when the
+// API could not be reached, so a failing API is never taken for an expired cookie.
+func cookieRejected(creds Credentials) (bool, error) {
+	req, err := newActivisionRequest("GET", url2, creds, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := doActivision(req, creds)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return true, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	return len(bytes.TrimSpace(body)) == 0, nil
+}
+
+// BanDetail is one game ban reported by the ban API.
+type BanDetail struct {
+	Title       string // The game the ban applies to.
+	Enforcement string // PERMANENT or UNDER_REVIEW.
+	CanAppeal   bool
+}
+
+func CheckAccount(creds Credentials) (models.Status, error) {
+	status, _, err := CheckAccountDetails(creds)
+	return status, err
+}
+
+// CheckAccountDetails returns the overall status of the account together with each
+// game ban reported for it.
+func CheckAccountDetails(creds Credentials) (models.Status, []BanDetail, error) {
 	logger.Log.Info("Starting CheckAccount function")
-	req, err := http.NewRequest("GET", url1, nil)
+	req, err := newActivisionRequest("GET", url1, creds, nil)
 	if err != nil {
-		return models.StatusUnknown, errors.New("failed to create HTTP request to check account")
+		return models.StatusUnknown, nil, errors.New("failed to create HTTP request to check account")
 	}
-	headers := GenerateHeaders(ssoCookie)
-	for k, v := range headers {
-		req.Header.Set(k, v)
+	resp, err := doActivision(req, creds)
+	if errors.Is(err, ErrCircuitOpen) {
+		return models.StatusUnknown, nil, err
 	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
 	if err != nil {
-		return models.StatusUnknown, errors.New("failed to send HTTP request to check account")
+		return models.StatusUnknown, nil, fmt.Errorf("failed to send HTTP request to check account: %w", err)
 	}
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return models.StatusUnknown, errors.New("failed to read response body from check account request")
+		return models.StatusUnknown, nil, errors.New("failed to read response body from check account request")
 	}
 	// logger.Log.Info("Response Body: ", string(body))
 	var data struct {
@@ -115,62 +150,96 @@ func CheckAccount(ssoCookie string) (models.Status, error) {
 			CanAppeal   bool   `json:"canAppeal"`
 		} `json:"bans"`
 	}
-	if string(body) == "" {
-		return models.StatusInvalidCookie, nil
+	if len(bytes.TrimSpace(body)) == 0 {
+		// The ban API answers an expired cookie with an empty body, but so does it when
+		// it is misbehaving. Ask the profile API before flipping the account to invalid.
+		rejected, err := cookieRejected(creds)
+		if err != nil {
+			return models.StatusUnknown, nil, fmt.Errorf("ban API returned status %d with an empty body and the cookie could not be verified: %w", resp.StatusCode, err)
+		}
+		if !rejected {
+			activision.Record(true)
+			return models.StatusUnknown, nil, fmt.Errorf("ban API returned status %d with an empty body for a valid cookie", resp.StatusCode)
+		}
+		return models.StatusInvalidCookie, nil, nil
 	}
-	err = json.NewDecoder(resp.Body).Decode(&data)
-	if err == nil {
-		return models.StatusUnknown, errors.New("failed to decode JSON response possible no response was received")
-	}
-	if len(data.Ban) == 0 {
-		return models.StatusGood, nil
-	} else {
-		for _, ban := range data.Ban {
-			if ban.Enforcement == "PERMANENT" {
-				return models.StatusPermaban, nil
-			} else if ban.Enforcement == "UNDER_REVIEW" {
-				return models.StatusShadowban, nil
-			} else {
-				return models.StatusGood, nil
-			}
+	err = json.Unmarshal(body, &data)
+	if err != nil {
+		return models.StatusUnknown, nil, errors.New("failed to decode JSON response possible no response was received")
+	}
+
+	status := models.StatusGood
+	bans := make([]BanDetail, len(data.Ban))
+	for i, ban := range data.Ban {
+		bans[i] = BanDetail{Title: ban.Title, Enforcement: ban.Enforcement, CanAppeal: ban.CanAppeal}
+		if ban.Enforcement == "PERMANENT" {
+			status = models.StatusPermaban
+		} else if ban.Enforcement == "UNDER_REVIEW" && status != models.StatusPermaban {
+			status = models.StatusShadowban
 		}
 	}
-	return models.StatusUnknown, nil
+	return status, bans, nil
+}
+
+// Profile is the part of an Activision profile the bot keeps.
+type Profile struct {
+	Created   time.Time
+	Username  string
+	BattleNet string
+	PSN       string
+	Xbox      string
+	Steam     string
 }
 
-func CheckAccountAge(ssoCookie string) (int, int, int, error) {
-	logger.Log.Info("Starting CheckAccountAge function")
-	req, err := http.NewRequest("GET", url2, nil)
+// FetchProfile returns when the Activision account was created and the platform
+// accounts linked to it.
+func FetchProfile(creds Credentials) (Profile, error) {
+	logger.Log.Info("Starting FetchProfile function")
+	req, err := newActivisionRequest("GET", url2, creds, nil)
 	if err != nil {
-		return 0, 0, 0, errors.New("failed to create HTTP request to check account age")
+		return Profile{}, errors.New("failed to create HTTP request to fetch account profile")
 	}
-	headers := GenerateHeaders(ssoCookie)
-	for k, v := range headers {
-		req.Header.Set(k, v)
+	resp, err := doActivision(req, creds)
+	if errors.Is(err, ErrCircuitOpen) {
+		return Profile{}, err
 	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
 	if err != nil {
-		return 0, 0, 0, errors.New("failed to send HTTP request to check account age")
+		return Profile{}, fmt.Errorf("failed to send HTTP request to fetch account profile: %w", err)
 	}
 	defer resp.Body.Close()
 	var data struct {
-		Created string `json:"created"`
+		Username string `json:"username"`
+		Created  string `json:"created"`
+		Accounts []struct {
+			Username string `json:"username"`
+			Provider string `json:"provider"`
+		} `json:"accounts"`
 	}
 	err = json.NewDecoder(resp.Body).Decode(&data)
 	if err != nil {
-		return 0, 0, 0, errors.New("failed to decode JSON response from check account age request")
+		return Profile{}, errors.New("failed to decode JSON response from account profile request")
 	}
 
 	created, err := time.Parse(time.RFC3339, data.Created)
 	if err != nil {
-		return 0, 0, 0, errors.New("failed to parse created date in check account age request")
+		return Profile{}, errors.New("failed to parse created date in account profile request")
+	}
+	profile := Profile{Created: created, Username: data.Username}
+	for _, account := range data.Accounts {
+		switch account.Provider {
+		case "battle":
+			profile.BattleNet = account.Username
+		case "psn":
+			profile.PSN = account.Username
+		case "xbl":
+			profile.Xbox = account.Username
+		case "steam":
+			profile.Steam = account.Username
+		case "uno":
+			if profile.Username == "" {
+				profile.Username = account.Username
+			}
+		}
 	}
-
-	duration := time.Since(created)
-	years := int(duration.Hours() / 24 / 365)
-	months := int(duration.Hours()/24/30) % 12
-	days := int(duration.Hours()/24) % 365 % 30
-
-	return years, months, days, nil
+	return profile, nil
 }
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/logger"
+)
+
+// singletonTTLs holds how long each RunSingleton lock is taken for, by job name.
+var (
+	singletonTTLs   = make(map[string]time.Duration)
+	singletonTTLsMu sync.Mutex
+)
+
+// RunSingleton runs job every interval on whichever instance holds the named lock, so
+// that jobs such as digests and ban wave checks run once even with several replicas.
+// The lock outlives the interval by the lease duration so a crashed leader is replaced.
+func RunSingleton(name string, interval time.Duration, job func()) {
+	singletonTTLsMu.Lock()
+	singletonTTLs[name] = interval + cfg.Cluster.LeaseDuration
+	singletonTTLsMu.Unlock()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	leader := false
+	for {
+		held, err := database.AcquireLock(name, cfg.Cluster.InstanceID, interval+cfg.Cluster.LeaseDuration)
+		if err != nil {
+			logger.Log.WithError(err).Errorf("Failed to acquire %s lock", name)
+		} else {
+			if held != leader {
+				logger.Log.WithField("job", name).Infof("Leadership changed, this instance is leader: %t", held)
+				leader = held
+			}
+			if held {
+				job()
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// RenewSingleton extends the named lock for a job that can run longer than the lock
+// is held for. It reports false if another instance has taken the lock over, in which
+// case the job should stop. Jobs not started through RunSingleton always keep going.
+func RenewSingleton(name string) bool {
+	singletonTTLsMu.Lock()
+	ttl, ok := singletonTTLs[name]
+	singletonTTLsMu.Unlock()
+	if !ok {
+		return true
+	}
+	held, err := database.AcquireLock(name, cfg.Cluster.InstanceID, ttl)
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to renew %s lock", name)
+		return false
+	}
+	return held
+}
@@ -1,141 +1,55 @@
 package services
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"strconv"
 	"time"
 
+	"codstatusbot2.0/config"
 	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
 	"codstatusbot2.0/logger"
 	"codstatusbot2.0/models"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/joho/godotenv"
+	"gorm.io/gorm"
 )
 
-var (
-	checkInterval        float64
-	notificationInterval float64
-	cooldownDuration     float64
-	sleepDuration        int
-)
-
-func init() {
-	err := godotenv.Load()
-	if err != nil {
-		logger.Log.WithError(err).Error("Failed to load .env file")
-	}
-
-	checkInterval, _ = strconv.ParseFloat(os.Getenv("CHECK_INTERVAL"), 64)
-	notificationInterval, _ = strconv.ParseFloat(os.Getenv("NOTIFICATION_INTERVAL"), 64)
-	cooldownDuration, _ = strconv.ParseFloat(os.Getenv("COOLDOWN_DURATION"), 64)
-	sleepDuration, _ = strconv.Atoi(os.Getenv("SLEEP_DURATION"))
-
-	logger.Log.Infof("Loaded config: CHECK_INTERVAL=%.2f, NOTIFICATION_INTERVAL=%.2f, COOLDOWN_DURATION=%.2f, SLEEP_DURATION=%d",
-		checkInterval, notificationInterval, cooldownDuration, sleepDuration)
-}
-
-func sendDailyUpdate(account models.Account, discord *discordgo.Session) {
-	logger.Log.Infof("Sending daily update for account %s", account.Title)
-
-	var description string
-	if account.IsExpiredCookie {
-		description = fmt.Sprintf("The SSO cookie for account %s has expired. Please update the cookie using the /updateaccount command or delete the account using the /removeaccount command.", account.Title)
-	} else {
-		description = fmt.Sprintf("The last status of account %s was %s.", account.Title, account.LastStatus)
-	}
-
-	embed := &discordgo.MessageEmbed{
-		Title:       fmt.Sprintf("%.2f Hour Update - %s", notificationInterval, account.Title),
-		Description: description,
-		Color:       GetColorForStatus(account.LastStatus, account.IsExpiredCookie),
-		Timestamp:   time.Now().Format(time.RFC3339),
-	}
-
-	var channelID string
-	if account.NotificationType == "dm" {
-		channel, err := discord.UserChannelCreate(account.UserID)
-		if err != nil {
-			logger.Log.WithError(err).Error("Failed to create DM channel")
-			return
-		}
-		channelID = channel.ID
-	} else {
-		channelID = account.ChannelID
-	}
-
-	_, err := discord.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
-		Embed: embed,
-	})
-	if err != nil {
-		logger.Log.WithError(err).Error("Failed to send scheduled update message for account", account.Title)
-
-	}
-
-	account.LastCheck = time.Now().Unix()
-	account.LastNotification = time.Now().Unix()
-	if err := database.DB.Save(&account).Error; err != nil {
-		logger.Log.WithError(err).Error("Failed to save account changes for account ", account.Title)
+var cfg = config.Default()
 
-	}
+// Configure sets the configuration used by the account checker. It must be called
+// before CheckAccounts is started.
+func Configure(c *config.Config) {
+	cfg = c
 }
 
-func CheckAccounts(s *discordgo.Session) {
-	for {
-		logger.Log.Info("Starting periodic account check")
-		var accounts []models.Account
-		if err := database.DB.Find(&accounts).Error; err != nil {
-			logger.Log.WithError(err).Error("Failed to fetch accounts from the database")
-			continue
-		}
-
-		for _, account := range accounts {
-			var lastCheck time.Time
-			if account.LastCheck != 0 {
-				lastCheck = time.Unix(account.LastCheck, 0)
-			}
-			var lastNotification time.Time
-			if account.LastNotification != 0 {
-				lastNotification = time.Unix(account.LastNotification, 0)
-			}
-
-			if account.IsExpiredCookie {
-				logger.Log.WithField(" account ", account.Title).Info(" Skipping account with expired cookie ")
-				if time.Since(lastNotification).Hours() > notificationInterval {
-					go sendDailyUpdate(account, s)
-				} else {
-
-					logger.Log.WithField(" account ", account.Title).Info(" Owner of ", account.Title, " recently notified within ", notificationInterval, " Hours already, skipping ")
-				}
-				continue
-			}
-			if time.Since(lastCheck).Minutes() > checkInterval {
-				go CheckSingleAccount(account, s)
-			} else {
-				logger.Log.WithField("account ", account.Title).Info(" Account ", account.Title, " checked recently less than ", checkInterval, " Minutes ago, skipping ")
-			}
-			if time.Since(lastNotification).Hours() > notificationInterval {
-				go sendDailyUpdate(account, s)
-			} else {
-				logger.Log.WithField(" account ", account.Title).Info(" Owner of ", account.Title, " recently notified within ", notificationInterval, " Hours already, skipping ")
-
-			}
-		}
-		time.Sleep(time.Duration(sleepDuration) * time.Minute)
+func CheckSingleAccount(account models.Account, discord discordapi.Session) {
+	result, bans, err := CheckAccountDetails(AccountCredentials(account))
+	if errors.Is(err, ErrCircuitOpen) {
+		// Leave the account due so it is checked as soon as the API recovers.
+		logger.Log.Infof("Skipping check of account %s while the Activision API is unavailable", account.Title)
+		return
 	}
-}
-
-func CheckSingleAccount(account models.Account, discord *discordgo.Session) {
-	result, err := CheckAccount(account.SSOCookie)
+	applyRotatedCookie(&account)
+	dueAt := account.NextCheckAt
+	account.NextCheckAt = time.Now().Add(checkIntervalFor(account)).Unix()
 	if err != nil {
 		logger.Log.WithError(err).Error("Failed to check account", account.Title, "possible expired SSO Cookie")
+		saveCheck(account, dueAt, map[string]interface{}{})
 		return
 	}
 
 	if result == models.StatusInvalidCookie {
+		if isMuted(account) {
+			logger.Log.Infof("Account %s has an invalid SSO cookie, skipping muted notification", account.Title)
+			account.IsExpiredCookie = true
+			if err := saveCheck(account, dueAt, map[string]interface{}{"is_expired_cookie": true}); err != nil {
+				logger.Log.WithError(err).Error("Failed to save account changes for account", account.Title)
+			}
+			return
+		}
 		lastNotification := time.Unix(account.LastCookieNotification, 0)
-		if time.Since(lastNotification) >= time.Duration(cooldownDuration)*time.Hour || account.LastCookieNotification == 0 {
+		if time.Since(lastNotification) >= cfg.Intervals.Cooldown || account.LastCookieNotification == 0 {
 			logger.Log.Infof("Account %s has an invalid SSO cookie ", account.Title)
 			embed := &discordgo.MessageEmbed{
 				Title:       fmt.Sprintf("%s - Invalid SSO Cookie ", account.Title),
@@ -143,31 +57,20 @@ func CheckSingleAccount(account models.Account, discord *discordgo.Session) {
 				Color:       0xff0000,
 				Timestamp:   time.Now().Format(time.RFC3339),
 			}
-			var channelID string
-			if account.NotificationType == "dm" {
-				channel, err := discord.UserChannelCreate(account.UserID)
-				if err != nil {
-					logger.Log.WithError(err).Error(" Failed to create DM channel ")
-					return
-				}
-				channelID = channel.ID
-			} else {
-				channelID = account.ChannelID
-			}
-			_, err = discord.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
-				Embed: embed,
-			})
-			if err != nil {
-				logger.Log.WithError(err).Error(" Failed to send invalid cookie notification for account ", account.Title)
-			}
+			notifyOwner(account, discord, "", embed, false)
 
 			account.LastCookieNotification = time.Now().Unix()
 			account.IsExpiredCookie = true
-			if err := database.DB.Save(&account).Error; err != nil {
+			err := saveCheck(account, dueAt, map[string]interface{}{
+				"is_expired_cookie":        true,
+				"last_cookie_notification": account.LastCookieNotification,
+			})
+			if err != nil {
 				logger.Log.WithError(err).Error("Failed to save account changes for account", account.Title)
 			}
 		} else {
 			logger.Log.Infof("Skipping expired cookie notification for account %s (cooldown)", account.Title)
+			saveCheck(account, dueAt, map[string]interface{}{})
 		}
 		return
 	}
@@ -175,54 +78,95 @@ func CheckSingleAccount(account models.Account, discord *discordgo.Session) {
 	lastStatus := account.LastStatus
 	account.LastCheck = time.Now().Unix()
 	account.IsExpiredCookie = false
-	if err := database.DB.Save(&account).Error; err != nil {
+	if err := saveCheck(account, dueAt, map[string]interface{}{"last_check": account.LastCheck, "is_expired_cookie": false}); err != nil {
 		logger.Log.WithError(err).Error("Failed to save account changes for account", account.Title)
 		return
 	}
+	warnCookieExpiry(&account, discord)
+	refreshStaleProfile(&account, discord)
+	syncGameBans(account, bans, discord)
 	if result != lastStatus {
+		applyRotatedCookie(&account)
 		account.LastStatus = result
-		if err := database.DB.Save(&account).Error; err != nil {
+		if err := database.DB.Model(&account).Update("last_status", result).Error; err != nil {
 			logger.Log.WithError(err).Error("Failed to save account changes for account", account.Title)
 			return
 		}
 		logger.Log.Infof("Account %s status changed to %s", account.Title, result)
 		ban := models.Ban{
-			Account:   account,
-			Status:    result,
-			AccountID: account.ID,
+			Account:        account,
+			Status:         result,
+			PreviousStatus: lastStatus,
+			AccountID:      account.ID,
+		}
+		if isBanStatus(result) && !isBanStatus(lastStatus) {
+			ban.BanWaveID = banWaveFor(discord, time.Now())
 		}
 		if err := database.DB.Create(&ban).Error; err != nil {
 			logger.Log.WithError(err).Error("Failed to create new ban record for account", account.Title)
 		}
 		embed := &discordgo.MessageEmbed{
 			Title:       fmt.Sprintf("%s - %s", account.Title, EmbedTitleFromStatus(result)),
-			Description: fmt.Sprintf("The status of account %s has changed to %s <@%s> ", account.Title, result, account.UserID),
+			Description: fmt.Sprintf("The status of account %s changed to %s <t:%d:f> <@%s> ", account.Title, result, account.LastCheck, account.UserID),
 			Color:       GetColorForStatus(result, account.IsExpiredCookie),
 			Timestamp:   time.Now().Format(time.RFC3339),
 		}
+		if active := ActiveGameBans(account.ID); len(active) > 0 {
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name:  "Game bans",
+				Value: GameBanSummary(active),
+			})
+		}
 
-		var channelID string
-		if account.NotificationType == "dm" {
-			channel, err := discord.UserChannelCreate(account.UserID)
-			if err != nil {
-				logger.Log.WithError(err).Error("Failed to create DM channel")
-				return
-			}
-			channelID = channel.ID
-		} else {
-			channelID = account.ChannelID
+		if isMuted(account) && account.MuteBanAlerts {
+			logger.Log.Infof("Skipping status change notification for muted account %s", account.Title)
+			return
+		}
+		content := fmt.Sprintf("<@%s>", account.UserID)
+		// A role mention only works in the guild, not in the owner's DMs.
+		if settings := GetGuildSettings(account.GuildID); settings.PingRoleID != "" && isBanStatus(result) && account.NotificationType != "dm" {
+			content += fmt.Sprintf(" <@&%s>", settings.PingRoleID)
 		}
 
-		_, err = discord.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
-			Embed:   embed,
-			Content: fmt.Sprintf("<@%s>", account.UserID),
-		})
-		if err != nil {
-			logger.Log.WithError(err).Error("Failed to send status update message for account", account.Title)
+		urgent := breaksQuiet(result, GetUserSettings(account.UserID))
+		if ban.BanWaveID != 0 {
+			logger.Log.Infof("Holding status change notification for account %s until ban wave %d is verified", account.Title, ban.BanWaveID)
+			if err := holdForBanWave(account, content, embed, ban.BanWaveID, urgent); err != nil {
+				logger.Log.WithError(err).Error("Failed to hold notification for account", account.Title)
+			}
+			return
 		}
+		notifyOwner(account, discord, content, embed, urgent)
 	}
 }
 
+// breaksQuiet reports whether an alert that the account changed to status is sent
+// during the owner's quiet hours instead of being held.
+func breaksQuiet(status models.Status, settings models.UserSettings) bool {
+	switch status {
+	case models.StatusPermaban:
+		return settings.PermabanBreaksQuiet
+	case models.StatusShadowban:
+		return settings.ShadowbanBreaksQuiet
+	}
+	return false
+}
+
+// saveCheck stores columns written by a check, together with when the next check is
+// due. Commands such as /pause, /mute and /updateaccount can change the row while the
+// check waits on Activision, so the account is never saved whole. A recheck requested
+// in the meantime reset next_check_at to 0, which is kept so that it still runs.
+func saveCheck(account models.Account, dueAt int64, columns map[string]interface{}) error {
+	columns["next_check_at"] = gorm.Expr("CASE WHEN next_check_at = ? THEN ? ELSE next_check_at END", dueAt, account.NextCheckAt)
+	return database.DB.Model(&account).Updates(columns).Error
+}
+
+// isMuted reports whether the account is left out of digests and cookie reminders for
+// it are suppressed. Ban alerts are only suppressed if MuteBanAlerts is also set.
+func isMuted(account models.Account) bool {
+	return account.MutedUntil > time.Now().Unix()
+}
+
 func GetColorForStatus(status models.Status, isExpiredCookie bool) int {
 	if isExpiredCookie {
 		return 0xff0000
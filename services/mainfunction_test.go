@@ -0,0 +1,174 @@
+package services
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"codstatusbot2.0/config"
+	"codstatusbot2.0/database/fakedb"
+	"codstatusbot2.0/discordapi/fake"
+	"codstatusbot2.0/models"
+)
+
+// fakeActivision answers the ban API with bans, where an empty body is what Activision
+// sends for an expired cookie, and the profile API with profileStatus.
+func fakeActivision(t *testing.T, bans string, profileStatus int) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/bans") {
+			w.Write([]byte(bans))
+		}
+		if strings.HasPrefix(r.URL.Path, "/api/profile") {
+			w.WriteHeader(profileStatus)
+			if profileStatus == http.StatusOK {
+				w.Write([]byte(`{"username":"player#1234","accounts":[]}`))
+			}
+		}
+	}))
+	previousBans, previousProfile, previousBreaker := url1, url2, activision
+	url1, url2, activision = server.URL+"/api/bans/appeal", server.URL+"/api/profile", &CircuitBreaker{}
+	t.Cleanup(func() {
+		server.Close()
+		url1, url2, activision = previousBans, previousProfile, previousBreaker
+	})
+}
+
+func TestCheckSingleAccountInvalidCookie(t *testing.T) {
+	Configure(config.Default())
+	tests := []struct {
+		name             string
+		notificationType string
+		profileStatus    int
+		forbidChannel    bool
+		closeDMs         bool
+		wantChannel      string // The channel the reminder is sent to, empty if none is sent.
+		wantExpired      bool
+	}{
+		{name: "channel", notificationType: "channel", profileStatus: http.StatusUnauthorized, wantChannel: "channel-1", wantExpired: true},
+		{name: "dm", notificationType: "dm", profileStatus: http.StatusUnauthorized, wantChannel: fake.DMChannelID("user-1"), wantExpired: true},
+		{name: "channel forbidden", notificationType: "channel", profileStatus: http.StatusUnauthorized, forbidChannel: true, wantExpired: true},
+		{name: "dms closed", notificationType: "dm", profileStatus: http.StatusUnauthorized, closeDMs: true, wantExpired: true},
+		{name: "cookie still accepted", notificationType: "channel", profileStatus: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := fakedb.Use(t)
+			fakeActivision(t, "", test.profileStatus)
+			session := fake.NewSession()
+			if test.forbidChannel {
+				session.Forbid("channel-1")
+			}
+			if test.closeDMs {
+				session.CloseDMs("user-1")
+			}
+			account := models.Account{
+				Title:            "main",
+				UserID:           "user-1",
+				ChannelID:        "channel-1",
+				NotificationType: test.notificationType,
+				SSOCookie:        "cookie",
+			}
+			account.ID = 1
+
+			CheckSingleAccount(account, session)
+
+			messages := session.Messages()
+			if test.wantChannel == "" {
+				if len(messages) != 0 {
+					t.Fatalf("sent %d messages, want none", len(messages))
+				}
+			} else {
+				if len(messages) != 1 || messages[0].ChannelID != test.wantChannel {
+					t.Fatalf("sent %+v, want one message to %s", messages, test.wantChannel)
+				}
+				if title := messages[0].Embeds[0].Title; !strings.Contains(title, "Invalid SSO Cookie") {
+					t.Errorf("embed title = %q, want an invalid cookie reminder", title)
+				}
+			}
+
+			// A failed delivery must not stop the account from being marked expired, or
+			// the reminder would be retried on every check.
+			expired := false
+			for _, exec := range db.Execs() {
+				if value, ok := exec.Set("is_expired_cookie"); ok && strings.HasPrefix(exec.Query, "UPDATE `accounts`") {
+					expired = value == true
+				}
+			}
+			if expired != test.wantExpired {
+				t.Errorf("account saved as expired = %v, want %v", expired, test.wantExpired)
+			}
+		})
+	}
+}
+
+func TestCheckSingleAccountBanAlert(t *testing.T) {
+	Configure(config.Default())
+	const (
+		permaban  = `{"bans":[{"enforcement":"PERMANENT","title":"mw2","canAppeal":false}]}`
+		shadowban = `{"bans":[{"enforcement":"UNDER_REVIEW","title":"mw2","canAppeal":true}]}`
+	)
+	tests := []struct {
+		name             string
+		bans             string
+		notificationType string
+		pingRole         string
+		wantStatus       models.Status
+		wantContent      string
+	}{
+		{name: "permaban", bans: permaban, notificationType: "channel", wantStatus: models.StatusPermaban, wantContent: "<@user-1>"},
+		{name: "permaban with ping role", bans: permaban, notificationType: "channel", pingRole: "role-1",
+			wantStatus: models.StatusPermaban, wantContent: "<@user-1> <@&role-1>"},
+		{name: "shadowban with ping role", bans: shadowban, notificationType: "channel", pingRole: "role-1",
+			wantStatus: models.StatusShadowban, wantContent: "<@user-1> <@&role-1>"},
+		{name: "ping role in dm", bans: permaban, notificationType: "dm", pingRole: "role-1",
+			wantStatus: models.StatusPermaban, wantContent: "<@user-1>"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := fakedb.Use(t)
+			if test.pingRole != "" {
+				db.Return("guild_settings", map[string]driver.Value{"guild_id": "guild-1", "ping_role_id": test.pingRole})
+			}
+			fakeActivision(t, test.bans, http.StatusOK)
+			session := fake.NewSession()
+			account := models.Account{
+				Title:            "main",
+				UserID:           "user-1",
+				GuildID:          "guild-1",
+				ChannelID:        "channel-1",
+				NotificationType: test.notificationType,
+				SSOCookie:        "cookie",
+				LastStatus:       models.StatusGood,
+			}
+			account.ID = 1
+
+			CheckSingleAccount(account, session)
+
+			var alerts []fake.Message
+			for _, message := range session.Messages() {
+				if len(message.Embeds) > 0 && strings.HasSuffix(message.Embeds[0].Title, EmbedTitleFromStatus(test.wantStatus)) {
+					alerts = append(alerts, message)
+				}
+			}
+			if len(alerts) != 1 {
+				t.Fatalf("sent %+v, want one ban alert", session.Messages())
+			}
+			if alerts[0].Content != test.wantContent {
+				t.Errorf("alert content = %q, want %q", alerts[0].Content, test.wantContent)
+			}
+
+			var saved driver.Value
+			for _, exec := range db.Execs() {
+				if value, ok := exec.Set("last_status"); ok {
+					saved = value
+				}
+			}
+			if saved != string(test.wantStatus) {
+				t.Errorf("saved status = %v, want %s", saved, test.wantStatus)
+			}
+		})
+	}
+}
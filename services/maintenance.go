@@ -0,0 +1,48 @@
+package services
+
+import (
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+)
+
+// PurgeAccount permanently deletes an account together with everything stored about it.
+func PurgeAccount(account models.Account) error {
+	tx := database.DB.Begin()
+	if err := tx.Unscoped().Where("account_id = ?", account.ID).Delete(&models.Ban{}).Error; err != nil {
+		logger.Log.WithError(err).Error("Error deleting associated bans for account", account.ID)
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Unscoped().Where("account_id = ?", account.ID).Delete(&models.AccountProfile{}).Error; err != nil {
+		logger.Log.WithError(err).Error("Error deleting profile for account", account.ID)
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Unscoped().Where("account_id = ?", account.ID).Delete(&models.RewardClaim{}).Error; err != nil {
+		logger.Log.WithError(err).Error("Error deleting reward claims for account", account.ID)
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Unscoped().Where("account_id = ?", account.ID).Delete(&models.GameBan{}).Error; err != nil {
+		logger.Log.WithError(err).Error("Error deleting game bans for account", account.ID)
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Unscoped().Where("account_id = ?", account.ID).Delete(&models.AuditLog{}).Error; err != nil {
+		logger.Log.WithError(err).Error("Error deleting audit log for account", account.ID)
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Unscoped().Where("account_id = ?", account.ID).Delete(&models.Notification{}).Error; err != nil {
+		logger.Log.WithError(err).Error("Error deleting held notifications for account", account.ID)
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Unscoped().Where("id = ?", account.ID).Delete(&models.Account{}).Error; err != nil {
+		logger.Log.WithError(err).Error("Error deleting account from database", account.ID)
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"encoding/json"
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// notifyOwner sends a message about an account to wherever its notifications go, or
+// holds it until the owner's quiet hours end. Urgent messages are never held.
+func notifyOwner(account models.Account, discord discordapi.Session, content string, embed *discordgo.MessageEmbed, urgent bool) {
+	if quietEnd, quiet := quietUntil(GetUserSettings(account.UserID), time.Now()); quiet && !urgent {
+		logger.Log.Infof("Holding notification for account %s until the end of quiet hours", account.Title)
+		if err := holdNotification(account, content, embed, quietEnd); err != nil {
+			logger.Log.WithError(err).Error("Failed to hold notification for account", account.Title)
+		}
+		return
+	}
+
+	channelID, err := notificationChannel(account, discord)
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to create DM channel")
+		return
+	}
+	_, err = discord.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embed:      embed,
+		Content:    content,
+		Components: AccountActionComponents(account.ID),
+	})
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to send notification for account", account.Title)
+	}
+}
+
+// holdNotification stores a message about an account so that it is sent at deliverAt
+// instead of now, e.g. because the owner is in their quiet hours.
+func holdNotification(account models.Account, content string, embed *discordgo.MessageEmbed, deliverAt time.Time) error {
+	return hold(models.Notification{AccountID: account.ID, Content: content}, embed, deliverAt)
+}
+
+// holdForBanWave stores a status change alert until the suspected ban wave it was
+// detected in is verified. If the wave is confirmed it is sent, after the owner's quiet
+// hours unless it is urgent.
+func holdForBanWave(account models.Account, content string, embed *discordgo.MessageEmbed, waveID uint, urgent bool) error {
+	deliverAt := time.Now()
+	if quietEnd, quiet := quietUntil(GetUserSettings(account.UserID), deliverAt); quiet && !urgent {
+		deliverAt = quietEnd
+	}
+	return hold(models.Notification{AccountID: account.ID, Content: content, BanWaveID: waveID}, embed, deliverAt)
+}
+
+func hold(notification models.Notification, embed *discordgo.MessageEmbed, deliverAt time.Time) error {
+	data, err := json.Marshal(embed)
+	if err != nil {
+		return err
+	}
+	notification.Embed = string(data)
+	notification.DeliverAt = deliverAt.Unix()
+	return database.DB.Create(&notification).Error
+}
+
+// deliverHeldNotifications sends the held notifications that have become due, except
+// those waiting for a ban wave to be verified. Each one is leased before it is sent so
+// that only one instance delivers it.
+func deliverHeldNotifications(router SessionRouter) {
+	now := time.Now()
+	var notifications []models.Notification
+	err := database.DB.Where("deliver_at <= ? AND lease_expires_at < ? AND ban_wave_id = ?", now.Unix(), now.Unix(), 0).
+		Order("deliver_at").
+		Limit(cfg.Scheduler.BatchSize).
+		Find(&notifications).Error
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to fetch held notifications")
+		return
+	}
+
+	for _, notification := range notifications {
+		result := database.DB.Model(&models.Notification{}).
+			Where("id = ? AND lease_expires_at < ?", notification.ID, now.Unix()).
+			Updates(map[string]interface{}{
+				"lease_owner":      cfg.Cluster.InstanceID,
+				"lease_expires_at": now.Add(cfg.Cluster.LeaseDuration).Unix(),
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+		deliverNotification(notification, router)
+	}
+}
+
+func deliverNotification(notification models.Notification, router SessionRouter) {
+	var account models.Account
+	if err := database.DB.First(&account, notification.AccountID).Error; err != nil {
+		logger.Log.WithError(err).Infof("Dropping held notification %d for a removed account", notification.ID)
+		database.DB.Unscoped().Delete(&notification)
+		return
+	}
+
+	var embed discordgo.MessageEmbed
+	if err := json.Unmarshal([]byte(notification.Embed), &embed); err != nil {
+		logger.Log.WithError(err).Errorf("Dropping malformed held notification %d", notification.ID)
+		database.DB.Unscoped().Delete(&notification)
+		return
+	}
+
+	message := &discordgo.MessageSend{
+		Embed:      &embed,
+		Content:    notification.Content,
+		Components: AccountActionComponents(account.ID),
+	}
+	discord := router.SessionForGuild(notificationGuild(account))
+	var channelID string
+	var err error
+	if notification.UserID != "" {
+		discord = router.SessionForGuild("")
+		message.Components = nil
+		var channel *discordgo.Channel
+		if channel, err = discord.UserChannelCreate(notification.UserID); err == nil {
+			channelID = channel.ID
+		}
+	} else {
+		channelID, err = notificationChannel(account, discord)
+	}
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to create DM channel")
+		return
+	}
+	_, err = discord.ChannelMessageSendComplex(channelID, message)
+	if err != nil {
+		// The lease expires on its own, after which another pass retries the delivery.
+		logger.Log.WithError(err).Error("Failed to send held notification for account", account.Title)
+		return
+	}
+	database.DB.Unscoped().Delete(&notification)
+}
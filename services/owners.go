@@ -0,0 +1,37 @@
+package services
+
+import (
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// IsBotOwner reports whether the user is one of the configured bot owners, who may
+// manage reward codes.
+func IsBotOwner(userID string) bool {
+	for _, ownerID := range cfg.Discord.OwnerIDs {
+		if ownerID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// alertOwners sends an operational alert to every bot owner by DM.
+func alertOwners(discord discordapi.Session, embed *discordgo.MessageEmbed) {
+	if len(cfg.Discord.OwnerIDs) == 0 {
+		logger.Log.Warnf("No bot owners configured to receive alert: %s", embed.Title)
+		return
+	}
+	for _, ownerID := range cfg.Discord.OwnerIDs {
+		channel, err := discord.UserChannelCreate(ownerID)
+		if err != nil {
+			logger.Log.WithError(err).Errorf("Failed to create DM channel for bot owner %s", ownerID)
+			continue
+		}
+		if _, err := discord.ChannelMessageSendEmbed(channel.ID, embed); err != nil {
+			logger.Log.WithError(err).Errorf("Failed to send alert to bot owner %s", ownerID)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+)
+
+// PrivacyPolicyVersion must be bumped whenever PrivacyPolicyText changes so that
+// every user is asked to accept the new policy before storing another cookie.
+const PrivacyPolicyVersion = 2
+
+const PrivacyPolicyText = "**COD Status Bot Privacy Policy (v2)**\n\n" +
+	"To monitor an account the bot stores the following data:\n" +
+	"- Your Discord user ID and the server and channel the account was added from.\n" +
+	"- The title you give the account and the Activision SSO cookie you provide.\n" +
+	"- The Activision username of the account and the Battle.net, PlayStation, Xbox and Steam usernames linked to it.\n" +
+	"- The results of every status check, the reward codes redeemed for the account and the times notifications were sent.\n\n" +
+	"The SSO cookie is used to query Activision's support API for the ban status, age and linked accounts of the account, " +
+	"and to redeem reward codes for the account, including codes the bot claims automatically. " +
+	"When Activision issues a new cookie in a response, the bot replaces the stored cookie with it.\n\n" +
+	"Requests to Activision may be sent through third-party proxy servers chosen by the bot operator, " +
+	"which means the cookie passes through those servers.\n\n" +
+	"The bot operator can export the database, including every stored cookie, to back it up or move the bot to another server. " +
+	"The cookie is not shared with anyone else and is not used for anything else.\n\n" +
+	"Removing an account with /removeaccount permanently deletes the cookie and all check history for that account.\n\n" +
+	"By pressing **Accept** you agree to the bot storing and using this data as described."
+
+func HasConsented(userID string) bool {
+	var consent models.Consent
+	if err := database.DB.Where("user_id = ?", userID).First(&consent).Error; err != nil {
+		return false
+	}
+	return consent.PolicyVersion >= PrivacyPolicyVersion
+}
+
+func RecordConsent(userID string) error {
+	var consent models.Consent
+	database.DB.Where("user_id = ?", userID).First(&consent)
+	consent.UserID = userID
+	consent.PolicyVersion = PrivacyPolicyVersion
+	consent.AcceptedAt = time.Now().Unix()
+	if err := database.DB.Save(&consent).Error; err != nil {
+		logger.Log.WithError(err).Errorf("Failed to record privacy policy consent for user %s", userID)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+
+	"github.com/bwmarrin/discordgo"
+	"gorm.io/gorm"
+)
+
+// profileRefreshInterval is how often the linked accounts of a profile are fetched
+// again to detect changes.
+const profileRefreshInterval = 24 * time.Hour
+
+// GetAccountProfile returns the stored profile of an account. ok is false if it has
+// not been fetched yet.
+func GetAccountProfile(accountID uint) (profile models.AccountProfile, ok bool) {
+	err := database.DB.Where("account_id = ?", accountID).First(&profile).Error
+	return profile, err == nil
+}
+
+// RefreshProfile fetches the account's profile, stores its creation date and linked
+// accounts and returns what changed since the previous fetch. No changes are reported
+// the first time a profile is fetched.
+func RefreshProfile(account *models.Account) ([]string, error) {
+	fetched, err := FetchProfile(AccountCredentials(*account))
+	if err != nil {
+		return nil, err
+	}
+
+	account.ActivisionCreated = fetched.Created.Unix()
+	if err := database.DB.Model(account).Update("activision_created", account.ActivisionCreated).Error; err != nil {
+		return nil, err
+	}
+
+	var profile models.AccountProfile
+	err = database.DB.Where("account_id = ?", account.ID).First(&profile).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	previous := profile
+	profile.AccountID = account.ID
+	profile.Username = fetched.Username
+	profile.BattleNet = fetched.BattleNet
+	profile.PSN = fetched.PSN
+	profile.Xbox = fetched.Xbox
+	profile.Steam = fetched.Steam
+	profile.FetchedAt = time.Now().Unix()
+	if err := database.DB.Save(&profile).Error; err != nil {
+		return nil, err
+	}
+
+	if previous.FetchedAt == 0 {
+		return nil, nil
+	}
+	return ProfileChanges(previous, profile), nil
+}
+
+// ProfileChanges describes the differences between two fetches of a profile.
+func ProfileChanges(previous, current models.AccountProfile) []string {
+	var changes []string
+	for _, field := range []struct {
+		name              string
+		previous, current string
+	}{
+		{"Activision username", previous.Username, current.Username},
+		{"Battle.net", previous.BattleNet, current.BattleNet},
+		{"PlayStation Network", previous.PSN, current.PSN},
+		{"Xbox", previous.Xbox, current.Xbox},
+		{"Steam", previous.Steam, current.Steam},
+	} {
+		switch {
+		case field.previous == field.current:
+		case field.previous == "":
+			changes = append(changes, fmt.Sprintf("%s account %s was linked", field.name, field.current))
+		case field.current == "":
+			changes = append(changes, fmt.Sprintf("%s account %s was unlinked", field.name, field.previous))
+		default:
+			changes = append(changes, fmt.Sprintf("%s changed from %s to %s", field.name, field.previous, field.current))
+		}
+	}
+	return changes
+}
+
+// LinkedAccounts lists the platform accounts linked to a profile, e.g.
+// "Battle.net: Name#1234, Steam: name".
+func LinkedAccounts(profile models.AccountProfile) string {
+	var linked []string
+	for _, platform := range []struct{ name, username string }{
+		{"Battle.net", profile.BattleNet},
+		{"PSN", profile.PSN},
+		{"Xbox", profile.Xbox},
+		{"Steam", profile.Steam},
+	} {
+		if platform.username != "" {
+			linked = append(linked, fmt.Sprintf("%s: %s", platform.name, platform.username))
+		}
+	}
+	if len(linked) == 0 {
+		return "None"
+	}
+	return strings.Join(linked, ", ")
+}
+
+// refreshStaleProfile fetches the profile of a successfully checked account at most
+// once a day and tells the owner when a linked account has changed. The creation date
+// it stores is also set on account.
+func refreshStaleProfile(account *models.Account, discord discordapi.Session) {
+	if profile, ok := GetAccountProfile(account.ID); ok && time.Since(time.Unix(profile.FetchedAt, 0)) < profileRefreshInterval {
+		return
+	}
+	changes, err := RefreshProfile(account)
+	if err != nil {
+		logger.Log.WithError(err).Error("Failed to refresh profile for account", account.Title)
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	logger.Log.Infof("Linked accounts of %s changed: %s", account.Title, strings.Join(changes, "; "))
+	if isMuted(*account) {
+		return
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("%s - Linked Accounts Changed", account.Title),
+		Description: "- " + strings.Join(changes, "\n- ") + "\n\nIf you did not make this change, secure your Activision account.",
+		Color:       0xffff00,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+	notifyOwner(*account, discord, fmt.Sprintf("<@%s>", account.UserID), embed, false)
+}
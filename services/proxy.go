@@ -0,0 +1,328 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"codstatusbot2.0/logger"
+)
+
+const (
+	ProxyRoundRobin = "round_robin"
+	ProxySticky     = "sticky"
+)
+
+// ProxyStats describes how an outbound proxy has performed since it was loaded.
+type ProxyStats struct {
+	Proxy      string
+	Healthy    bool
+	Requests   int64
+	Failures   int64
+	AvgLatency time.Duration
+}
+
+type proxyEntry struct {
+	url       string
+	label     string // The proxy without credentials, safe to log.
+	transport *http.Transport
+	client    *http.Client
+
+	mu           sync.Mutex
+	nextRequest  time.Time
+	consecutive  int
+	healthy      bool
+	requests     int64
+	failures     int64
+	totalLatency time.Duration
+}
+
+// proxyPool spreads requests to Activision over the configured outbound proxies.
+// Requests go out directly when no proxy is configured. When none is healthy they fail
+// with ErrNoHealthyProxy, unless direct fallback is turned on.
+type proxyPool struct {
+	mu      sync.RWMutex
+	entries []*proxyEntry
+	next    atomic.Uint64
+	direct  atomic.Bool // Set while no proxy is healthy.
+}
+
+// ErrNoHealthyProxy is returned instead of sending a request from the bot's own IP
+// while every configured proxy is ejected and direct fallback is off.
+var ErrNoHealthyProxy = errors.New("no healthy outbound proxy")
+
+var (
+	proxies        = &proxyPool{}
+	startProxyOnce sync.Once
+)
+
+// startProxies loads the proxy list and keeps it up to date. The list is reloaded and
+// every proxy is health checked at the configured interval, so proxies can be added
+// or removed without a restart.
+func startProxies() {
+	startProxyOnce.Do(func() {
+		proxies.reload()
+		proxies.healthCheck()
+		go func() {
+			ticker := time.NewTicker(cfg.Proxies.HealthCheckInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				proxies.reload()
+				proxies.healthCheck()
+			}
+		}()
+	})
+}
+
+// ProxyPoolStats returns the statistics of every loaded proxy.
+func ProxyPoolStats() []ProxyStats {
+	proxies.mu.RLock()
+	defer proxies.mu.RUnlock()
+	stats := make([]ProxyStats, len(proxies.entries))
+	for i, entry := range proxies.entries {
+		stats[i] = entry.stats()
+	}
+	return stats
+}
+
+// proxyURLs returns the proxies from the configuration followed by those in the proxy
+// file, one per line, ignoring blank lines and lines starting with #.
+func proxyURLs() []string {
+	urls := append([]string{}, cfg.Proxies.URLs...)
+	if cfg.Proxies.File == "" {
+		return urls
+	}
+	file, err := os.Open(cfg.Proxies.File)
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to read proxy file %s", cfg.Proxies.File)
+		return urls
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			urls = append(urls, line)
+		}
+	}
+	return urls
+}
+
+// reload replaces the proxy list, keeping the state of proxies that are still listed.
+func (p *proxyPool) reload() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	existing := make(map[string]*proxyEntry, len(p.entries))
+	for _, entry := range p.entries {
+		existing[entry.url] = entry
+	}
+	entries := make([]*proxyEntry, 0, len(p.entries))
+	seen := make(map[string]bool)
+	added := 0
+	for _, raw := range proxyURLs() {
+		if seen[raw] {
+			continue
+		}
+		seen[raw] = true
+		if entry, ok := existing[raw]; ok {
+			entries = append(entries, entry)
+			continue
+		}
+		entry, err := newProxyEntry(raw)
+		if err != nil {
+			logger.Log.WithError(err).Error("Skipping invalid proxy")
+			continue
+		}
+		entries = append(entries, entry)
+		added++
+	}
+	removed := 0
+	for raw, entry := range existing {
+		if !seen[raw] {
+			entry.transport.CloseIdleConnections()
+			removed++
+		}
+	}
+	if added > 0 || removed > 0 {
+		logger.Log.Infof("Loaded %d outbound proxies (%d added, %d removed)", len(entries), added, removed)
+	}
+	p.entries = entries
+}
+
+func newProxyEntry(raw string) (*proxyEntry, error) {
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q in %s", proxyURL.Scheme, proxyURL.Redacted())
+	}
+	transport := newTransport(http.ProxyURL(proxyURL))
+	return &proxyEntry{
+		url:       raw,
+		label:     proxyURL.Scheme + "://" + proxyURL.Host,
+		transport: transport,
+		client:    newClient(transport),
+		healthy:   true,
+	}, nil
+}
+
+// pick returns the proxy a request is sent through, or nil to send it directly. It
+// returns ErrNoHealthyProxy when proxies are configured but none is healthy and direct
+// fallback is off. In sticky mode the same key, which identifies the account, keeps
+// using the same proxy for as long as it is healthy.
+func (p *proxyPool) pick(key string) (*proxyEntry, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	healthy := make([]*proxyEntry, 0, len(p.entries))
+	for _, entry := range p.entries {
+		if entry.isHealthy() {
+			healthy = append(healthy, entry)
+		}
+	}
+	if len(healthy) == 0 {
+		if len(p.entries) == 0 {
+			return nil, nil
+		}
+		if !cfg.Proxies.DirectFallback {
+			if !p.direct.Swap(true) {
+				logger.Log.Warn("No healthy outbound proxy, holding requests until one recovers")
+			}
+			return nil, ErrNoHealthyProxy
+		}
+		if !p.direct.Swap(true) {
+			logger.Log.Warn("No healthy outbound proxy, sending requests directly until one recovers")
+		}
+		return nil, nil
+	}
+	p.direct.Store(false)
+	if cfg.Proxies.Assignment != ProxySticky {
+		return healthy[p.next.Add(1)%uint64(len(healthy))], nil
+	}
+
+	// Rendezvous hashing keeps most keys on the same proxy when one is ejected.
+	var best *proxyEntry
+	var bestScore uint64
+	for _, entry := range healthy {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte(entry.url))
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = entry, score
+		}
+	}
+	return best, nil
+}
+
+// healthCheck sends a test request through every proxy, ejecting those that fail and
+// bringing back those that recovered, then logs each proxy's statistics.
+func (p *proxyPool) healthCheck() {
+	p.mu.RLock()
+	entries := append([]*proxyEntry{}, p.entries...)
+	p.mu.RUnlock()
+
+	for _, entry := range entries {
+		req, err := newActivisionRequest("GET", url1, Credentials{}, nil)
+		var resp *http.Response
+		if err == nil {
+			resp, err = entry.client.Do(req)
+		}
+		if err == nil {
+			resp.Body.Close()
+			if failedStatus(resp.StatusCode) {
+				err = fmt.Errorf("status %d", resp.StatusCode)
+			}
+		}
+		entry.mu.Lock()
+		wasHealthy := entry.healthy
+		entry.healthy = err == nil
+		if entry.healthy {
+			entry.consecutive = 0
+		}
+		entry.mu.Unlock()
+		if err != nil && wasHealthy {
+			logger.Log.WithError(err).Warnf("Proxy %s failed its health check and was ejected", entry.label)
+		} else if err == nil && !wasHealthy {
+			logger.Log.Infof("Proxy %s passed its health check and is back in use", entry.label)
+		}
+
+		stats := entry.stats()
+		logger.Log.WithField("proxy", stats.Proxy).Infof("Proxy stats: healthy=%t requests=%d failures=%d avg_latency=%s",
+			stats.Healthy, stats.Requests, stats.Failures, stats.AvgLatency)
+	}
+}
+
+func (e *proxyEntry) isHealthy() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.healthy
+}
+
+// wait blocks until the proxy's rate limit allows another request.
+func (e *proxyEntry) wait() {
+	e.mu.Lock()
+	slot := time.Now()
+	if e.nextRequest.After(slot) {
+		slot = e.nextRequest
+	}
+	e.nextRequest = slot.Add(cfg.Proxies.RequestInterval)
+	e.mu.Unlock()
+	time.Sleep(time.Until(slot))
+}
+
+// record updates the proxy's statistics and ejects it after too many failures in a row.
+// It is brought back by the next successful health check.
+func (e *proxyEntry) record(failed bool, latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requests++
+	e.totalLatency += latency
+	if !failed {
+		e.consecutive = 0
+		return
+	}
+	e.failures++
+	e.consecutive++
+	if e.healthy && e.consecutive >= cfg.Proxies.MaxFailures {
+		logger.Log.Warnf("Ejecting proxy %s after %d failed requests in a row", e.label, e.consecutive)
+		e.healthy = false
+	}
+}
+
+func (e *proxyEntry) stats() ProxyStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	stats := ProxyStats{Proxy: e.label, Healthy: e.healthy, Requests: e.requests, Failures: e.failures}
+	if e.requests > 0 {
+		stats.AvgLatency = e.totalLatency / time.Duration(e.requests)
+	}
+	return stats
+}
+
+// sendThroughProxy sends a request for an account through the proxy picked for it, or
+// directly when there is none, and keeps any cookie Activision rotated.
+func sendThroughProxy(req *http.Request, creds Credentials) (*http.Response, error) {
+	entry, err := proxies.pick(creds.key())
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return sendWithJar(httpClient(), req, creds)
+	}
+	entry.wait()
+	start := time.Now()
+	resp, err := sendWithJar(entry.client, req, creds)
+	entry.record(err != nil || failedStatus(resp.StatusCode), time.Since(start))
+	return resp, err
+}
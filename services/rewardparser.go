@@ -0,0 +1,154 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	ClaimSuccess         = "claimed"
+	ClaimAlreadyRedeemed = "already_claimed"
+	ClaimInvalidCode     = "invalid_code"
+	ClaimFailed          = "failed"
+)
+
+// ClaimOutcome is the result of redeeming one reward code for one account.
+type ClaimOutcome struct {
+	Result string // One of the Claim constants.
+	Detail string // What was unlocked, or why the code was rejected.
+}
+
+// Done reports whether the code never needs to be tried again for the account.
+func (o ClaimOutcome) Done() bool {
+	return o.Result == ClaimSuccess || o.Result == ClaimAlreadyRedeemed
+}
+
+// ParseRedemptionResponse interprets the page returned after redeeming a code. Only
+// the text of the redemption message is classified: the element whose class starts
+// with "redemption-", or an element with role="alert" if the page has none. Navigation
+// and footer text often contains words like "sign in" or "expired", so matching the
+// whole page would misread it. A page with a password field and no message is the
+// sign-in page Activision shows when the cookie is not accepted.
+func ParseRedemptionResponse(body []byte) ClaimOutcome {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return ClaimOutcome{Result: ClaimFailed, Detail: "the response could not be read"}
+	}
+
+	var message, alert *html.Node
+	success, signIn := false, false
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if n.Data == "script" || n.Data == "style" {
+				return
+			}
+			for _, class := range strings.Fields(attribute(n, "class")) {
+				if message == nil && strings.HasPrefix(class, "redemption-") {
+					message = n
+				}
+				if class == "redemption-success" {
+					success = true
+				}
+			}
+			if alert == nil && attribute(n, "role") == "alert" {
+				alert = n
+			}
+			if n.Data == "input" && attribute(n, "type") == "password" {
+				signIn = true
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	if message == nil {
+		message = alert
+	}
+	if message == nil {
+		if signIn {
+			return ClaimOutcome{Result: ClaimFailed, Detail: "the SSO cookie was not accepted"}
+		}
+		return ClaimOutcome{Result: ClaimFailed, Detail: "unexpected response"}
+	}
+
+	text := nodeText(message)
+	lower := strings.ToLower(text)
+	switch {
+	case success || strings.Contains(lower, "just unlocked") || strings.Contains(lower, "successfully redeemed"):
+		unlocked := highlightedText(message)
+		if unlocked == "" {
+			unlocked = textAfter(text, lower, "just unlocked")
+		}
+		return ClaimOutcome{Result: ClaimSuccess, Detail: unlocked}
+	case strings.Contains(lower, "already") && (strings.Contains(lower, "redeemed") || strings.Contains(lower, "claimed")):
+		return ClaimOutcome{Result: ClaimAlreadyRedeemed, Detail: "already redeemed on this account"}
+	case strings.Contains(lower, "invalid") || strings.Contains(lower, "not valid") || strings.Contains(lower, "expired"):
+		return ClaimOutcome{Result: ClaimInvalidCode, Detail: "the code is invalid or has expired"}
+	case strings.Contains(lower, "sign in") || strings.Contains(lower, "log in"):
+		return ClaimOutcome{Result: ClaimFailed, Detail: "the SSO cookie was not accepted"}
+	default:
+		return ClaimOutcome{Result: ClaimFailed, Detail: "unexpected response"}
+	}
+}
+
+// highlightedText returns the text of the first element inside n with the
+// accent-highlight class, which names the unlocked item on success.
+func highlightedText(n *html.Node) string {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode {
+			for _, class := range strings.Fields(attribute(child, "class")) {
+				if class == "accent-highlight" {
+					return nodeText(child)
+				}
+			}
+		}
+		if text := highlightedText(child); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+func attribute(n *html.Node, name string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func nodeText(n *html.Node) string {
+	var parts []string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			if text := strings.TrimSpace(n.Data); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+	return strings.Join(parts, " ")
+}
+
+// textAfter returns the text following marker, up to the end of the sentence. lower is
+// text in lower case and is used to find the marker without caring about case.
+func textAfter(text, lower, marker string) string {
+	i := strings.Index(lower, marker)
+	if i == -1 || len(lower) != len(text) {
+		return ""
+	}
+	rest := strings.TrimLeft(text[i+len(marker):], " :")
+	if end := strings.IndexAny(rest, ".!\n"); end != -1 {
+		rest = rest[:end]
+	}
+	return strings.TrimSpace(rest)
+}
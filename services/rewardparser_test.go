@@ -0,0 +1,40 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRedemptionResponse(t *testing.T) {
+	tests := []struct {
+		file string
+		want ClaimOutcome
+	}{
+		{"success.html", ClaimOutcome{Result: ClaimSuccess, Detail: "Operator Skin: Ghost Reaper"}},
+		{"already_redeemed.html", ClaimOutcome{Result: ClaimAlreadyRedeemed, Detail: "already redeemed on this account"}},
+		{"invalid.html", ClaimOutcome{Result: ClaimInvalidCode, Detail: "the code is invalid or has expired"}},
+		{"expired_alert.html", ClaimOutcome{Result: ClaimInvalidCode, Detail: "the code is invalid or has expired"}},
+		{"sign_in.html", ClaimOutcome{Result: ClaimFailed, Detail: "the SSO cookie was not accepted"}},
+		{"no_message.html", ClaimOutcome{Result: ClaimFailed, Detail: "unexpected response"}},
+	}
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", "redemption", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ParseRedemptionResponse(body); got != test.want {
+				t.Errorf("ParseRedemptionResponse(%s) = %+v, want %+v", test.file, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseRedemptionResponseUnlockedText(t *testing.T) {
+	body := []byte(`<div class="redemption-success">Just unlocked: Double XP Token. Enjoy!</div>`)
+	want := ClaimOutcome{Result: ClaimSuccess, Detail: "Double XP Token"}
+	if got := ParseRedemptionResponse(body); got != want {
+		t.Errorf("ParseRedemptionResponse = %+v, want %+v", got, want)
+	}
+}
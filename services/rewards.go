@@ -0,0 +1,187 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	rewardPool     *WorkerPool
+	rewardPoolOnce sync.Once
+
+	rewardLimiterMu   sync.Mutex
+	nextRewardRequest time.Time
+
+	rewardCodePattern = regexp.MustCompile(`^[A-Z0-9]{4,32}$`)
+)
+
+// ClaimReport is the outcome of one code in a claim run.
+type ClaimReport struct {
+	Code    models.RewardCode
+	Outcome ClaimOutcome
+	Skipped bool // The code had already been claimed for the account in an earlier run.
+}
+
+// rewardWorkers returns the pool that limits how many codes are redeemed at once,
+// shared by every claim so that several users claiming together cannot flood the site.
+func rewardWorkers() *WorkerPool {
+	rewardPoolOnce.Do(func() {
+		rewardPool = NewWorkerPool(cfg.Rewards.Workers)
+	})
+	return rewardPool
+}
+
+// waitForRewardSlot blocks until the next redemption request may be sent, spacing
+// requests from every claim by the configured interval.
+func waitForRewardSlot() {
+	rewardLimiterMu.Lock()
+	slot := nextRewardRequest
+	if now := time.Now(); slot.Before(now) {
+		slot = now
+	}
+	nextRewardRequest = slot.Add(cfg.Rewards.RequestInterval)
+	rewardLimiterMu.Unlock()
+	time.Sleep(time.Until(slot))
+}
+
+// NormalizeRewardCode upper-cases a code and strips the spaces and dashes users tend
+// to paste with it.
+func NormalizeRewardCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+func AddRewardCode(code, description, addedBy string) (models.RewardCode, error) {
+	code = NormalizeRewardCode(code)
+	if !rewardCodePattern.MatchString(code) {
+		return models.RewardCode{}, fmt.Errorf("%q is not a valid reward code", code)
+	}
+	rewardCode := models.RewardCode{Code: code, Description: description, AddedBy: addedBy}
+	err := database.DB.Create(&rewardCode).Error
+	return rewardCode, err
+}
+
+// RemoveRewardCode deletes a code so that it is no longer offered. The claim ledger
+// for it is deleted as well.
+func RemoveRewardCode(code string) error {
+	var rewardCode models.RewardCode
+	if err := database.DB.Where("code = ?", NormalizeRewardCode(code)).First(&rewardCode).Error; err != nil {
+		return err
+	}
+	tx := database.DB.Begin()
+	if err := tx.Unscoped().Where("reward_code_id = ?", rewardCode.ID).Delete(&models.RewardClaim{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Unscoped().Delete(&rewardCode).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func ListRewardCodes() ([]models.RewardCode, error) {
+	var codes []models.RewardCode
+	err := database.DB.Order("created_at desc").Find(&codes).Error
+	return codes, err
+}
+
+// ClaimRewards redeems every published code that has not been claimed for the account
+// yet. Codes claimed in an earlier run are reported as skipped and never retried.
+func ClaimRewards(account models.Account) ([]ClaimReport, error) {
+	codes, err := ListRewardCodes()
+	if err != nil {
+		return nil, err
+	}
+	return claimCodes(account, codes)
+}
+
+func claimCodes(account models.Account, codes []models.RewardCode) ([]ClaimReport, error) {
+	if len(codes) == 0 {
+		return nil, nil
+	}
+	var claims []models.RewardClaim
+	err := database.DB.Where("account_id = ? AND result IN ?", account.ID, []string{ClaimSuccess, ClaimAlreadyRedeemed}).Find(&claims).Error
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[uint]models.RewardClaim, len(claims))
+	for _, claim := range claims {
+		done[claim.RewardCodeID] = claim
+	}
+
+	reports := make([]ClaimReport, len(codes))
+	var wg sync.WaitGroup
+	for i, code := range codes {
+		if claim, ok := done[code.ID]; ok {
+			reports[i] = ClaimReport{Code: code, Outcome: ClaimOutcome{Result: claim.Result, Detail: claim.Detail}, Skipped: true}
+			continue
+		}
+		i, code := i, code
+		rewardWorkers().Go(&wg, func() {
+			waitForRewardSlot()
+			outcome, err := ClaimSingleReward(AccountCredentials(account), code.Code)
+			if err != nil {
+				logger.Log.WithError(err).Errorf("Failed to claim code %s for account %s", code.Code, account.Title)
+				outcome = ClaimOutcome{Result: ClaimFailed, Detail: "the reward service could not be reached"}
+			}
+			if err := recordClaim(account.ID, code.ID, outcome); err != nil {
+				logger.Log.WithError(err).Errorf("Failed to record claim of code %s for account %s", code.Code, account.Title)
+			}
+			reports[i] = ClaimReport{Code: code, Outcome: outcome}
+		})
+	}
+	wg.Wait()
+	return reports, nil
+}
+
+func recordClaim(accountID, codeID uint, outcome ClaimOutcome) error {
+	claim := models.RewardClaim{
+		AccountID:    accountID,
+		RewardCodeID: codeID,
+		Result:       outcome.Result,
+		Detail:       outcome.Detail,
+		Attempts:     1,
+	}
+	updates := clause.AssignmentColumns([]string{"result", "detail", "updated_at"})
+	updates = append(updates, clause.Assignment{Column: clause.Column{Name: "attempts"}, Value: gorm.Expr("attempts + 1")})
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "account_id"}, {Name: "reward_code_id"}},
+		DoUpdates: updates,
+	}).Create(&claim).Error
+}
+
+// FormatClaimReport renders one line per code for a claim run.
+func FormatClaimReport(reports []ClaimReport) string {
+	if len(reports) == 0 {
+		return "There are no reward codes to claim right now."
+	}
+	lines := make([]string, len(reports))
+	for i, report := range reports {
+		label := report.Code.Code
+		if report.Code.Description != "" {
+			label = fmt.Sprintf("%s (%s)", report.Code.Code, report.Code.Description)
+		}
+		switch {
+		case report.Skipped:
+			lines[i] = fmt.Sprintf("- %s: already claimed earlier", label)
+		case report.Outcome.Result == ClaimSuccess && report.Outcome.Detail != "":
+			lines[i] = fmt.Sprintf("- %s: unlocked %s", label, report.Outcome.Detail)
+		case report.Outcome.Result == ClaimSuccess:
+			lines[i] = fmt.Sprintf("- %s: claimed", label)
+		default:
+			lines[i] = fmt.Sprintf("- %s: %s", label, report.Outcome.Detail)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/discordapi"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	pool *WorkerPool
+	wake = make(chan struct{}, 1)
+)
+
+// EnqueuePriorityCheck marks the account as due immediately and wakes the scheduler
+// so that it is checked without waiting for the next pass.
+func EnqueuePriorityCheck(accountID uint) {
+	err := database.DB.Model(&models.Account{}).Where("id = ?", accountID).Update("next_check_at", 0).Error
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Failed to enqueue priority check for account %d", accountID)
+		return
+	}
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// SessionRouter returns the Discord session that should be used to send messages
+// about accounts in a guild. DMs are sent through the session for guild "".
+type SessionRouter interface {
+	SessionForGuild(guildID string) discordapi.Session
+}
+
+func CheckAccounts(router SessionRouter) {
+	pool = NewWorkerPool(cfg.Scheduler.Workers)
+	watchBreaker(router)
+	for {
+		if activision.Probe() {
+			logger.Log.Info("Starting periodic account check")
+			processDueAccounts(router)
+		} else {
+			logger.Log.Warn("Account checks are paused while the Activision API is unavailable")
+		}
+		deliverHeldNotifications(router)
+
+		wait := timeUntilNextDue()
+		if retry := activision.RetryIn(); retry > 0 && retry < wait {
+			wait = retry
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-wake:
+			timer.Stop()
+			logger.Log.Info("Woken early for priority check")
+		}
+	}
+}
+
+// processDueAccounts walks the accounts that are due for a check in ID order, one
+// batch at a time. Each batch is leased to this instance before it is processed so
+// that other instances skip it.
+func processDueAccounts(router SessionRouter) {
+	now := time.Now().Unix()
+	var lastID uint
+	for {
+		var ids []uint
+		err := dueAccounts(now).
+			Where("lease_expires_at < ?", now).
+			Where("id > ?", lastID).
+			Order("id").
+			Limit(cfg.Scheduler.BatchSize).
+			Pluck("id", &ids).Error
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to fetch due accounts from the database")
+			return
+		}
+		if len(ids) == 0 {
+			return
+		}
+
+		accounts, err := database.ClaimAccounts(ids, cfg.Cluster.InstanceID, cfg.Cluster.LeaseDuration)
+		if err != nil {
+			logger.Log.WithError(err).Error("Failed to claim due accounts")
+			return
+		}
+		if len(accounts) < len(ids) {
+			logger.Log.Infof("%d due accounts are being handled by another instance", len(ids)-len(accounts))
+		}
+
+		var wg sync.WaitGroup
+		for _, account := range accounts {
+			account := account
+			pool.Go(&wg, func() {
+				defer releaseAccount(account)
+				CheckSingleAccount(account, router.SessionForGuild(notificationGuild(account)))
+			})
+		}
+		wg.Wait()
+
+		lastID = ids[len(ids)-1]
+		if len(ids) < cfg.Scheduler.BatchSize || !activision.Allow() {
+			return
+		}
+	}
+}
+
+// notificationGuild returns the guild whose shard delivers notifications for the
+// account, or "" when they are sent by DM.
+func notificationGuild(account models.Account) string {
+	if account.NotificationType == "dm" {
+		return ""
+	}
+	return account.GuildID
+}
+
+func releaseAccount(account models.Account) {
+	if err := database.ReleaseAccount(account.ID, cfg.Cluster.InstanceID); err != nil {
+		logger.Log.WithError(err).Errorf("Failed to release lease on account %s", account.Title)
+	}
+}
+
+func dueAccounts(now int64) *gorm.DB {
+	return database.DB.Model(&models.Account{}).
+		Where("is_expired_cookie = ? AND next_check_at <= ? AND paused_until <= ?", false, now, now)
+}
+
+// timeUntilNextDue returns how long the scheduler can sleep before the earliest
+// account or held notification becomes due, capped at the configured sleep duration.
+func timeUntilNextDue() time.Duration {
+	var nextCheck, nextHeld sql.NullInt64
+	now := time.Now()
+	database.DB.Model(&models.Account{}).Where("is_expired_cookie = ? AND paused_until <= ?", false, now.Unix()).Select("MIN(next_check_at)").Scan(&nextCheck)
+	database.DB.Model(&models.Notification{}).Where("ban_wave_id = ?", 0).Select("MIN(deliver_at)").Scan(&nextHeld)
+
+	wait := cfg.Intervals.Sleep
+	for _, next := range []sql.NullInt64{nextCheck, nextHeld} {
+		if !next.Valid {
+			continue
+		}
+		if d := time.Unix(next.Int64, 0).Sub(now); d < wait {
+			wait = d
+		}
+	}
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait
+}
+
+func checkIntervalFor(account models.Account) time.Duration {
+	if account.CheckInterval > 0 {
+		return time.Duration(account.CheckInterval) * time.Minute
+	}
+	return cfg.Intervals.Check
+}
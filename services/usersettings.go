@@ -0,0 +1,54 @@
+package services
+
+import (
+	"time"
+
+	"codstatusbot2.0/database"
+	"codstatusbot2.0/logger"
+	"codstatusbot2.0/models"
+)
+
+// GetUserSettings returns a user's settings, or the defaults if they have never
+// changed them.
+func GetUserSettings(userID string) models.UserSettings {
+	settings := models.UserSettings{
+		UserID:              userID,
+		Timezone:            "UTC",
+		QuietStart:          22,
+		QuietEnd:            8,
+		PermabanBreaksQuiet: true,
+	}
+	database.DB.Where("user_id = ?", userID).First(&settings)
+	return settings
+}
+
+// quietUntil reports whether now falls within the user's quiet hours and, if it does,
+// when they end. Windows that cross midnight, such as 22 to 8, are supported.
+func quietUntil(settings models.UserSettings, now time.Time) (time.Time, bool) {
+	if !settings.QuietHoursEnabled || settings.QuietStart == settings.QuietEnd {
+		return time.Time{}, false
+	}
+	location, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		logger.Log.WithError(err).Errorf("Invalid timezone %q for user %s", settings.Timezone, settings.UserID)
+		location = time.UTC
+	}
+	local := now.In(location)
+	hour := local.Hour()
+
+	var inQuiet bool
+	if settings.QuietStart < settings.QuietEnd {
+		inQuiet = hour >= settings.QuietStart && hour < settings.QuietEnd
+	} else {
+		inQuiet = hour >= settings.QuietStart || hour < settings.QuietEnd
+	}
+	if !inQuiet {
+		return time.Time{}, false
+	}
+
+	end := time.Date(local.Year(), local.Month(), local.Day(), settings.QuietEnd, 0, 0, 0, location)
+	if !end.After(local) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end, true
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"sync"
+
+	"codstatusbot2.0/logger"
+)
+
+// WorkerPool runs submitted tasks on a fixed number of goroutines so that a large
+// batch of accounts cannot open an unbounded number of connections to Activision.
+type WorkerPool struct {
+	tasks chan func()
+	once  sync.Once
+}
+
+func NewWorkerPool(size int) *WorkerPool {
+	if size < 1 {
+		size = 1
+	}
+	p := &WorkerPool{tasks: make(chan func())}
+	for w := 0; w < size; w++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *WorkerPool) work() {
+	for task := range p.tasks {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Log.Errorf("Recovered from panic in worker: %v", r)
+				}
+			}()
+			task()
+		}()
+	}
+}
+
+// Submit blocks until a worker is free to run the task.
+func (p *WorkerPool) Submit(task func()) {
+	p.tasks <- task
+}
+
+// Go submits the task and marks it done on wg once it has finished.
+func (p *WorkerPool) Go(wg *sync.WaitGroup, task func()) {
+	wg.Add(1)
+	p.Submit(func() {
+		defer wg.Done()
+		task()
+	})
+}
+
+func (p *WorkerPool) Stop() {
+	p.once.Do(func() { close(p.tasks) })
+}